@@ -6,7 +6,7 @@ import (
 
 	"github.com/1v4n-ML/finance-tracker-api/config"
 	"github.com/1v4n-ML/finance-tracker-api/routes"
-	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"github.com/1v4n-ML/finance-tracker-api/services"
 	"github.com/joho/godotenv"
 	"gopkg.in/robfig/cron.v2"
 )
@@ -22,20 +22,33 @@ func main() {
 
 	// Setup database connection
 	db := config.ConnectDatabase(AppConfig)
+	config.EnsureIndexes(db)
+
+	// Apply pending data migrations before serving requests
+	if err := services.RunMigrations(db, AppConfig, context.Background()); err != nil {
+		log.Fatalf("Failed to run migrations: %v", err)
+	}
 
 	// Setup router with routes
 	router := routes.SetupRouter(db, AppConfig)
 
 	//Setup scheduler
+	// Balances are kept up to date by every transaction write, recalculation is an on-demand repair tool
 	c := cron.New()
-	_, err := c.AddFunc("*/3 * * * *", func() {
-		utils.RecalculateAllBalancesService(db, context.Background())
+	_, err := c.AddFunc("@every 15m", func() {
+		services.MaterializeRecurrencesService(db, context.Background(), AppConfig.Currency.Base)
+	})
+	if err != nil {
+		log.Fatalf("catastrophic failure when starting recurrences CRON task")
+	}
+	_, err = c.AddFunc("@daily", func() {
+		services.PurgeTrashService(db, context.Background(), AppConfig.Trash.RetentionDays)
 	})
 	if err != nil {
-		log.Fatalf("catastrophic failure when starting CRON task")
+		log.Fatalf("catastrophic failure when starting trash purge CRON task")
 	}
 	c.Start()
-	log.Println("starting scheduler to run at every 3 min")
+	log.Println("starting scheduler to materialize recurrences at every 15 min and purge the trash daily")
 
 	// Start server
 	// Listen on all interfaces (0.0.0.0) on the specified port
@@ -4,6 +4,8 @@ import (
 	"context"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/1v4n-ML/finance-tracker-api/utils"
@@ -21,6 +23,8 @@ const (
 	defaultDbTimeout      = 5 * time.Second  // Default for standard DB operations
 	defaultRequestTimeout = 30 * time.Second // Default for reports/aggregations
 	defaultServerPort     = "8080"           // Default server port
+	defaultBaseCurrency   = "BRL"            // Currency of accounts and transactions created without one
+	defaultTrashRetention = 30               // Days deleted documents stay in the trash before being purged
 	connectTimeout        = 10 * time.Second // Timeout for initial DB connection
 )
 
@@ -38,9 +42,24 @@ type Config struct {
 		Database time.Duration
 		Request  time.Duration
 	}
+	Currency struct {
+		Base string // ISO 4217 code used when an account or transaction has no currency
+	}
+	Classifier struct {
+		AutoApplyThreshold float64 // Minimum confidence to apply a suggested category on creation and imports, 0 disables it
+	}
+	Trash struct {
+		RetentionDays int // Days deleted transactions, accounts and categories can be restored
+	}
 	ApiToken string
+	// ApiKeys maps extra API keys to the identity of who uses them, recorded as the actor in the audit log.
+	// ApiToken is the identity DefaultActor
+	ApiKeys map[string]string
 }
 
+// DefaultActor is the identity of requests authenticated with API_SECRET_TOKEN
+const DefaultActor = "default"
+
 // LoadConfig loads configuration from environment variables or defaults
 func LoadConfig() *Config {
 	config := &Config{}
@@ -58,11 +77,30 @@ func LoadConfig() *Config {
 	// --- Server Configuration ---
 	config.Server.Port = utils.GetEnvOrDefault("SERVER_PORT", defaultServerPort)
 
+	// --- Currency Configuration ---
+	config.Currency.Base = strings.ToUpper(utils.GetEnvOrDefault("BASE_CURRENCY", defaultBaseCurrency))
+
+	// --- Category Suggestion Configuration ---
+	threshold, err := strconv.ParseFloat(utils.GetEnvOrDefault("AUTO_CATEGORIZE_THRESHOLD", "0"), 64)
+	if err != nil || threshold < 0 || threshold > 1 {
+		log.Printf("WARNING: Invalid AUTO_CATEGORIZE_THRESHOLD, expected a number between 0 and 1. Auto-categorization disabled.")
+		threshold = 0
+	}
+	config.Classifier.AutoApplyThreshold = threshold
+
+	// --- Trash Configuration ---
+	retention, err := strconv.Atoi(utils.GetEnvOrDefault("TRASH_RETENTION_DAYS", strconv.Itoa(defaultTrashRetention)))
+	if err != nil || retention < 1 {
+		log.Printf("WARNING: Invalid TRASH_RETENTION_DAYS, expected a positive number of days. Using %d.", defaultTrashRetention)
+		retention = defaultTrashRetention
+	}
+	config.Trash.RetentionDays = retention
+
 	// --- Timeout Configuration ---
 	config.Timeouts.Database = utils.ParseTimeout(dbTimeoutEnvVar, defaultDbTimeout)
 	config.Timeouts.Request = utils.ParseTimeout(reportTimeoutEnvVar, defaultRequestTimeout)
 
-	log.Printf("Loaded configuration: Port=%s, DB=%s", config.Server.Port, config.MongoDB.Database)
+	log.Printf("Loaded configuration: Port=%s, DB=%s, BaseCurrency=%s", config.Server.Port, config.MongoDB.Database, config.Currency.Base)
 	log.Printf("Loaded timeouts: DB=%v, Request=%v", config.Timeouts.Database, config.Timeouts.Request)
 
 	envTestVar := os.Getenv("TESTENV")
@@ -70,8 +108,20 @@ func LoadConfig() *Config {
 
 	// --- Authentication ---
 	config.ApiToken = os.Getenv("API_SECRET_TOKEN")
-	if config.ApiToken == "" {
-		log.Println("WARNING: API_SECRET_TOKEN is not set. API will be insecure.")
+	// API_KEYS gives each household member a key of their own: "ana:key1,bruno:key2"
+	config.ApiKeys = map[string]string{}
+	for _, entry := range strings.Split(os.Getenv("API_KEYS"), ",") {
+		name, key, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || strings.TrimSpace(name) == "" || strings.TrimSpace(key) == "" {
+			if entry != "" {
+				log.Printf("WARNING: Ignoring malformed API_KEYS entry, expected name:key.")
+			}
+			continue
+		}
+		config.ApiKeys[strings.TrimSpace(key)] = strings.TrimSpace(name)
+	}
+	if config.ApiToken == "" && len(config.ApiKeys) == 0 {
+		log.Println("WARNING: Neither API_SECRET_TOKEN nor API_KEYS is set. API will be insecure.")
 		log.Fatal()
 	}
 
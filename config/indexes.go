@@ -0,0 +1,87 @@
+package config
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnsureIndexes creates the indexes the API relies on at startup.
+// Creating an index that already exists with the same definition is a no-op
+func EnsureIndexes(db *mongo.Database) {
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+
+	indexes := map[string][]mongo.IndexModel{
+		"transactions": {
+			{
+				// One transaction per recurrence occurrence, so the scheduler can't create duplicates
+				Keys: bson.D{{Key: "recurrence_id", Value: 1}, {Key: "occurrence_date", Value: 1}},
+				Options: options.Index().
+					SetName("recurrence_occurrence_unique").
+					SetUnique(true).
+					SetPartialFilterExpression(bson.M{"recurrence_id": bson.M{"$exists": true}}),
+			},
+			{
+				// A bank transaction is imported only once per account
+				Keys: bson.D{{Key: "account_id", Value: 1}, {Key: "external_id", Value: 1}},
+				Options: options.Index().
+					SetName("account_external_id_unique").
+					SetUnique(true).
+					SetPartialFilterExpression(bson.M{"external_id": bson.M{"$exists": true}}),
+			},
+			{
+				// Keyset pagination of the transaction list, newest first by default
+				Keys:    bson.D{{Key: "date", Value: -1}, {Key: "_id", Value: -1}},
+				Options: options.Index().SetName("date_id"),
+			},
+			{
+				// Tag filters and the tag list
+				Keys:    bson.D{{Key: "tags", Value: 1}},
+				Options: options.Index().SetName("tags"),
+			},
+			{
+				// Full-text search. Text indexes ignore case and diacritics; Portuguese stems the words,
+				// so "padarias" finds "Padaria". A match in the description counts more than one in the tags
+				Keys: bson.D{{Key: "description", Value: "text"}, {Key: "tags", Value: "text"}},
+				Options: options.Index().
+					SetName("description_tags_text").
+					SetDefaultLanguage("portuguese").
+					SetWeights(bson.D{{Key: "description", Value: 10}, {Key: "tags", Value: 5}}),
+			},
+			{
+				// Trash listing and the retention purge; live transactions aren't indexed
+				Keys:    bson.D{{Key: "deleted_at", Value: 1}},
+				Options: options.Index().SetName("deleted_at").SetSparse(true),
+			},
+		},
+		"audit_log": {
+			{
+				// History of one document, and the entity and time range filters
+				Keys:    bson.D{{Key: "entity", Value: 1}, {Key: "entity_id", Value: 1}, {Key: "timestamp", Value: -1}},
+				Options: options.Index().SetName("entity_entity_id_timestamp"),
+			},
+			{
+				Keys:    bson.D{{Key: "timestamp", Value: -1}},
+				Options: options.Index().SetName("timestamp"),
+			},
+		},
+		"exchange_rates": {
+			{
+				// One rate per pair and day, also used by the report currency conversion lookup
+				Keys:    bson.D{{Key: "pair", Value: 1}, {Key: "date", Value: -1}},
+				Options: options.Index().SetName("pair_date_unique").SetUnique(true),
+			},
+		},
+	}
+
+	for collection, models := range indexes {
+		if _, err := db.Collection(collection).Indexes().CreateMany(ctx, models); err != nil {
+			log.Fatalf("FATAL: Failed to create indexes for %s: %v", collection, err)
+		}
+	}
+	log.Println("Database indexes ensured")
+}
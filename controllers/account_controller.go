@@ -2,11 +2,15 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/1v4n-ML/finance-tracker-api/config"
 	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/services"
 	"github.com/1v4n-ML/finance-tracker-api/utils"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
@@ -28,13 +32,35 @@ func NewAccountController(db *mongo.Database, cfg *config.Config) *AccountContro
 	}
 }
 
+// accountSorts are the sort query values of GetAllAccounts
+var accountSorts = map[string]string{"name": "name", "balance": "balance", "created_at": "created_at"}
+
+// GetAllAccounts returns a page of accounts, by name unless sort says otherwise.
+// Filters: type, currency and name (case-insensitive substring)
 func (ac *AccountController) GetAllAccounts(c *gin.Context) {
 	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), ac.cfg.Timeouts.Request)
 	defer cancel()
 
-	cursor, err := ac.col.Find(ctx, bson.M{})
+	page, ok := parsePage(c, accountSorts, "name")
+	if !ok {
+		return
+	}
+
+	filter := bson.M{}
+	if value := c.Query("type"); value != "" {
+		filter["type"] = value
+	}
+	if value := c.Query("currency"); value != "" {
+		filter["currency"] = strings.ToUpper(value)
+	}
+	if value := c.Query("name"); value != "" {
+		filter["name"] = bson.M{"$regex": regexp.QuoteMeta(value), "$options": "i"}
+	}
+
+	cursor, err := ac.col.Find(ctx, page.Filter(utils.NotDeleted(filter)), page.FindOptions())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 	defer func(cursor *mongo.Cursor, ctz context.Context) {
 		err := cursor.Close(ctx)
@@ -42,13 +68,13 @@ func (ac *AccountController) GetAllAccounts(c *gin.Context) {
 		}
 	}(cursor, ctx)
 
-	var accounts []models.Account
+	accounts := []models.Account{}
 	if err = cursor.All(ctx, &accounts); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, &accounts)
+	respondPage(c, page, accounts)
 }
 
 func (ac *AccountController) GetAccountById(c *gin.Context) {
@@ -63,12 +89,13 @@ func (ac *AccountController) GetAccountById(c *gin.Context) {
 
 	var account models.Account
 
-	err = ac.col.FindOne(ctx, bson.M{"_id": id}).Decode(&account)
+	err = ac.col.FindOne(ctx, utils.NotDeleted(bson.M{"_id": id})).Decode(&account)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
 		return
 	}
 
+	setETag(c, account.Version)
 	c.JSON(http.StatusOK, account)
 }
 
@@ -86,13 +113,24 @@ func (ac *AccountController) CreateAccount(c *gin.Context) {
 	account.ID = primitive.NewObjectID()
 	account.CreatedAt = time.Now()
 	account.Balance = 0
+	account.DeletedAt = nil
+	account.Version = 0 // A new document starts at version 0, whatever the client sent
+	if account.Currency == "" {
+		account.Currency = ac.cfg.Currency.Base
+	}
 
-	result, err := ac.col.InsertOne(ctx, account)
+	// The account and its audit entry are written in the same MongoDB transaction
+	err := utils.RunInTransaction(ctx, ac.db, func(sessCtx mongo.SessionContext) error {
+		if _, err := ac.col.InsertOne(sessCtx, account); err != nil {
+			return err
+		}
+		return services.RecordAudit(sessCtx, ac.db, services.AuditAccount, services.AuditCreate, account.ID, nil, account)
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusCreated, gin.H{"id": result.InsertedID})
+	c.JSON(http.StatusCreated, gin.H{"id": account.ID})
 }
 
 func (ac *AccountController) UpdateAccount(c *gin.Context) {
@@ -105,26 +143,131 @@ func (ac *AccountController) UpdateAccount(c *gin.Context) {
 		return
 	}
 
+	version, ok := expectedVersion(c)
+	if !ok {
+		return
+	}
 	var account models.Account
 	if err := c.ShouldBindBodyWithJSON(&account); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	account.UpdatedAt = time.Now()
-	_, err = ac.col.UpdateOne(
-		ctx,
-		bson.M{"_id": id},
-		bson.M{"$set": account},
-	)
+	var existing models.Account
+	if err := ac.col.FindOne(ctx, utils.NotDeleted(bson.M{"_id": id})).Decode(&existing); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+		return
+	}
+	if existing.Version != version {
+		staleVersion(c, existing.Version)
+		return
+	}
+
+	ac.save(c, ctx, existing, account, version)
+}
+
+// PatchAccount changes only the fields sent, as an RFC 7396 merge patch. The balance can't be patched
+func (ac *AccountController) PatchAccount(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), ac.cfg.Timeouts.Request)
+	defer cancel()
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	version, ok := expectedVersion(c)
+	if !ok {
+		return
+	}
+
+	var existing models.Account
+	if err := ac.col.FindOne(ctx, utils.NotDeleted(bson.M{"_id": id})).Decode(&existing); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+		return
+	}
+	if existing.Version != version {
+		staleVersion(c, existing.Version)
+		return
+	}
+
+	var account models.Account
+	if !applyMergePatch(c, existing, &account, "balance") {
+		return
+	}
+
+	ac.save(c, ctx, existing, account, version)
+}
+
+// save writes the client-editable fields of account over existing, at the version the client read
+func (ac *AccountController) save(c *gin.Context, ctx context.Context, existing, account models.Account, version int64) {
+	id := existing.ID
+
+	// Balances are kept in the account currency, so it can only change while the account is unused
+	if account.Currency == "" {
+		account.Currency = existing.Currency
+	}
+	if account.Currency != existing.Currency {
+		used, err := ac.db.Collection("transactions").CountDocuments(ctx, bson.M{"$or": bson.A{
+			bson.M{"account_id": id},
+			bson.M{"destination_account_id": id},
+		}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if used > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "currency can't change on an account with transactions"})
+			return
+		}
+	}
+
+	// The balance is maintained by the transaction writes, never by the client, and moves without a
+	// version change, so it's left out of the $set
+	update := utils.BumpVersion(bson.M{"$set": bson.M{
+		"name":        account.Name,
+		"type":        account.Type,
+		"currency":    account.Currency,
+		"color":       account.Color,
+		"closure_day": account.ClosureDay,
+		"payday":      account.PayDay,
+		"updated_at":  time.Now(),
+	}})
+	var after models.Account
+	err := utils.RunInTransaction(ctx, ac.db, func(sessCtx mongo.SessionContext) error {
+		var before models.Account
+		// The version is checked and incremented by the same write
+		err := ac.col.FindOneAndUpdate(sessCtx, utils.MatchVersion(utils.NotDeleted(bson.M{"_id": id}), version), update).Decode(&before)
+		if err != nil {
+			return err
+		}
+		if err := ac.col.FindOne(sessCtx, bson.M{"_id": id}).Decode(&after); err != nil {
+			return err
+		}
+		return services.RecordAudit(sessCtx, ac.db, services.AuditAccount, services.AuditUpdate, id, before, after)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Changed or deleted between the read and the write
+		var current models.Account
+		if err := ac.col.FindOne(ctx, utils.NotDeleted(bson.M{"_id": id})).Decode(&current); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+			return
+		}
+		staleVersion(c, current.Version)
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "account updated"})
+	setETag(c, after.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "account updated", "version": after.Version})
 }
 
+// DeleteAccount deletes an account. While transactions, recurrences or rules use it the delete is refused with 409,
+// unless ?reassign_to=<account id> moves them to another account or ?cascade=true deletes them too (rules are disabled)
 func (ac *AccountController) DeleteAccount(c *gin.Context) {
 	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), ac.cfg.Timeouts.Request)
 	defer cancel()
@@ -135,20 +278,48 @@ func (ac *AccountController) DeleteAccount(c *gin.Context) {
 		return
 	}
 
-	_, err = ac.col.DeleteOne(ctx, bson.M{"_id": id})
+	mode, target, err := services.ParseDeleteMode(c.Query("reassign_to"), c.Query("cascade"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "account deleted"})
+	// The account and everything pointing at it change in the same MongoDB transaction
+	var usage services.ReferenceUsage
+	err = utils.RunInTransaction(ctx, ac.db, func(sessCtx mongo.SessionContext) error {
+		var err error
+		usage, err = services.DeleteAccount(sessCtx, ac.db, id, mode, target, ac.cfg.Currency.Base)
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		case errors.Is(err, services.ErrReferenceInUse):
+			c.JSON(http.StatusConflict, gin.H{"error": "account " + err.Error(), "usage": usage})
+		case services.IsReferenceError(err):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "account deleted", "usage": usage})
 }
 
+// RecalculateAllBalances compares every balance with its transactions and fixes the ones that drifted.
+// With dry_run=true the differences are only reported
 func (ac *AccountController) RecalculateAllBalances(c *gin.Context) {
 	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), ac.cfg.Timeouts.Request)
 	defer cancel()
 
-	utils.RecalculateAllBalancesService(ac.db, ctx)
+	dryRun := c.Query("dry_run") == "true"
+	differences, err := utils.RecalculateAllBalancesService(ac.db, ctx, !dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Todos os saldos foram recalculados com sucesso."})
+	c.JSON(http.StatusOK, gin.H{"dry_run": dryRun, "differences": differences})
 }
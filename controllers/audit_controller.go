@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/1v4n-ML/finance-tracker-api/config"
+	"github.com/1v4n-ML/finance-tracker-api/services"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type AuditController struct {
+	db  *mongo.Database
+	cfg *config.Config
+}
+
+func NewAuditController(db *mongo.Database, cfg *config.Config) *AuditController {
+	return &AuditController{
+		db:  db,
+		cfg: cfg,
+	}
+}
+
+// GetAll returns the audit log, newest first. Optional query: entity (transaction, account or category),
+// entity_id, actor, start_date and end_date (DATE_LAYOUT) and limit (default 100, at most 1000)
+func (ac *AuditController) GetAll(c *gin.Context) {
+	filter := bson.M{}
+	if entity := c.Query("entity"); entity != "" {
+		if entity != services.AuditTransaction && entity != services.AuditAccount && entity != services.AuditCategory {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid entity, expected transaction, account or category"})
+			return
+		}
+		filter["entity"] = entity
+	}
+	if raw := c.Query("entity_id"); raw != "" {
+		entityID, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid entity_id"})
+			return
+		}
+		filter["entity_id"] = entityID
+	}
+	if actor := c.Query("actor"); actor != "" {
+		filter["actor"] = actor
+	}
+	timeFilter := bson.M{}
+	if raw := c.Query("start_date"); raw != "" {
+		start, err := utils.ParseDateToISO(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_date: " + err.Error()})
+			return
+		}
+		timeFilter["$gte"] = start
+	}
+	if raw := c.Query("end_date"); raw != "" {
+		end, err := utils.ParseDateToISO(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "end_date: " + err.Error()})
+			return
+		}
+		timeFilter["$lt"] = end.AddDate(0, 0, 1)
+	}
+	if len(timeFilter) > 0 {
+		filter["timestamp"] = timeFilter
+	}
+	limit := defaultAuditLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxAuditLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit, expected a number between 1 and 1000"})
+			return
+		}
+		limit = parsed
+	}
+
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), ac.cfg.Timeouts.Request)
+	defer cancel()
+
+	entries, err := services.ListAudit(ctx, ac.db, filter, int64(limit))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
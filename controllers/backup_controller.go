@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/config"
+	"github.com/1v4n-ML/finance-tracker-api/services"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type BackupController struct {
+	db  *mongo.Database
+	cfg *config.Config
+}
+
+func NewBackupController(db *mongo.Database, cfg *config.Config) *BackupController {
+	return &BackupController{
+		db:  db,
+		cfg: cfg,
+	}
+}
+
+// Export streams a zip archive with every collection as JSON Lines. It isn't bounded by the request
+// timeout, which would cut large exports short; it stops when the client goes away
+func (bc *BackupController) Export(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	filename := fmt.Sprintf("finance-backup-%s.zip", time.Now().Format("20060102-150405"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	// The archive is streamed, so once it started an error can only cut it short
+	if err := services.WriteBackup(ctx, bc.db, c.Writer); err != nil {
+		log.Default().Printf("backup export failed: %v", err)
+		c.Abort()
+	}
+}
+
+// Restore loads a backup archive ("file" form field). An empty database is restored as is,
+// a non-empty one gets the backup merged in with new ids
+func (bc *BackupController) Restore(c *gin.Context) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing backup archive in 'file' field"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	// Like the export, a large restore takes longer than the request timeout
+	report, err := services.RestoreBackup(c.Request.Context(), bc.db, bc.cfg, file, header.Size)
+	if err != nil {
+		// Only a bad archive is the client's fault; the report tells whether a failed restore was rolled back
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidBackup) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error(), "report": report})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/config"
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/services"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type BudgetController struct {
+	db  *mongo.Database
+	col *mongo.Collection
+	cfg *config.Config
+}
+
+func NewBudgetController(db *mongo.Database, cfg *config.Config) *BudgetController {
+	return &BudgetController{
+		db:  db,
+		col: db.Collection("budgets"),
+		cfg: cfg,
+	}
+}
+
+// GetAll returns all budgets
+func (bc *BudgetController) GetAll(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), bc.cfg.Timeouts.Request)
+	defer cancel()
+
+	cursor, err := bc.col.Find(ctx, bson.M{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err := cursor.Close(ctx)
+		if err != nil {
+
+		}
+	}(cursor, ctx)
+
+	budgets := []models.Budget{}
+	if err = cursor.All(ctx, &budgets); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, budgets)
+}
+
+// GetByID returns a single budget by ID
+func (bc *BudgetController) GetByID(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), bc.cfg.Timeouts.Request)
+	defer cancel()
+
+	budget, ok := bc.findBudget(c, ctx)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, budget)
+}
+
+// Create adds a new budget
+func (bc *BudgetController) Create(c *gin.Context) {
+	var budget models.Budget
+	if err := c.ShouldBindJSON(&budget); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateBudget(budget); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), bc.cfg.Timeouts.Request)
+	defer cancel()
+
+	budget.ID = primitive.NewObjectID()
+	budget.CreatedAt = time.Now()
+	result, err := bc.col.InsertOne(ctx, budget)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": result.InsertedID})
+}
+
+// Update modifies an existing budget
+func (bc *BudgetController) Update(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), bc.cfg.Timeouts.Request)
+	defer cancel()
+
+	existing, ok := bc.findBudget(c, ctx)
+	if !ok {
+		return
+	}
+
+	var budget models.Budget
+	if err := c.ShouldBindJSON(&budget); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateBudget(budget); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	budget.ID = existing.ID
+	budget.CreatedAt = existing.CreatedAt
+	budget.UpdatedAt = time.Now()
+	_, err := bc.col.ReplaceOne(ctx, bson.M{"_id": existing.ID}, budget)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "budget updated"})
+}
+
+// Delete removes a budget
+func (bc *BudgetController) Delete(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), bc.cfg.Timeouts.Request)
+	defer cancel()
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	_, err = bc.col.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "budget deleted"})
+}
+
+// Progress returns spent, remaining and percent used of a budget.
+// The optional `date` query (DATE_LAYOUT) selects the period, defaulting to the current one
+func (bc *BudgetController) Progress(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), bc.cfg.Timeouts.Request)
+	defer cancel()
+
+	budget, ok := bc.findBudget(c, ctx)
+	if !ok {
+		return
+	}
+
+	at := time.Now()
+	if raw := c.Query("date"); raw != "" {
+		parsed, err := utils.ParseDateToISO(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date: " + err.Error()})
+			return
+		}
+		at = parsed
+	}
+
+	progress, err := services.ComputeBudgetProgress(ctx, bc.db, budget, at)
+	if err != nil {
+		if errors.Is(err, services.ErrOutsideBudget) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, progress)
+}
+
+func (bc *BudgetController) findBudget(c *gin.Context, ctx context.Context) (models.Budget, bool) {
+	var budget models.Budget
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return budget, false
+	}
+
+	if err := bc.col.FindOne(ctx, bson.M{"_id": id}).Decode(&budget); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Budget not found"})
+		return budget, false
+	}
+	return budget, true
+}
+
+func validateBudget(budget models.Budget) error {
+	if budget.EndDate != nil && !budget.EndDate.After(budget.StartDate) {
+		return errors.New("end_date must be after start_date")
+	}
+	return nil
+}
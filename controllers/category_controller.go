@@ -2,11 +2,14 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"regexp"
 	"time"
 
 	"github.com/1v4n-ML/finance-tracker-api/config"
 	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/services"
 	"github.com/1v4n-ML/finance-tracker-api/utils"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
@@ -28,12 +31,39 @@ func NewCategoryController(db *mongo.Database, cfg *config.Config) *CategoryCont
 	}
 }
 
-// GetAllCategories returns all categories
+// categorySorts are the sort query values of GetAllCategories
+var categorySorts = map[string]string{"name": "name", "created_at": "created_at"}
+
+// GetAllCategories returns a page of categories, by name unless sort says otherwise.
+// Filters: type, parent_id ("none" for the top-level ones) and name (case-insensitive substring)
 func (cc *CategoryController) GetAllCategories(c *gin.Context) {
 	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), cc.cfg.Timeouts.Request)
 	defer cancel()
 
-	cursor, err := cc.col.Find(ctx, bson.M{})
+	page, ok := parsePage(c, categorySorts, "name")
+	if !ok {
+		return
+	}
+
+	filter := bson.M{}
+	if value := c.Query("type"); value != "" {
+		filter["type"] = value
+	}
+	if value := c.Query("parent_id"); value == "none" {
+		filter["parent_id"] = bson.M{"$exists": false}
+	} else if value != "" {
+		id, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid parent_id"})
+			return
+		}
+		filter["parent_id"] = id
+	}
+	if value := c.Query("name"); value != "" {
+		filter["name"] = bson.M{"$regex": regexp.QuoteMeta(value), "$options": "i"}
+	}
+
+	cursor, err := cc.col.Find(ctx, page.Filter(utils.NotDeleted(filter)), page.FindOptions())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -45,13 +75,48 @@ func (cc *CategoryController) GetAllCategories(c *gin.Context) {
 		}
 	}(cursor, ctx)
 
-	var categories []models.Category
+	categories := []models.Category{}
 	if err = cursor.All(ctx, &categories); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, categories)
+	respondPage(c, page, categories)
+}
+
+// GetCategoryTree returns the categories nested under their parents
+func (cc *CategoryController) GetCategoryTree(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), cc.cfg.Timeouts.Request)
+	defer cancel()
+
+	tree, err := services.CategoryTree(ctx, cc.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tree)
+}
+
+// GetCategory returns a single category, with its version as the ETag
+func (cc *CategoryController) GetCategory(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), cc.cfg.Timeouts.Request)
+	defer cancel()
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var category models.Category
+	if err := cc.col.FindOne(ctx, utils.NotDeleted(bson.M{"_id": id})).Decode(&category); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+		return
+	}
+
+	setETag(c, category.Version)
+	c.JSON(http.StatusOK, category)
 }
 
 // CreateCategory adds a new category
@@ -65,15 +130,32 @@ func (cc *CategoryController) CreateCategory(c *gin.Context) {
 	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), cc.cfg.Timeouts.Request)
 	defer cancel()
 
+	if err := services.ValidateCategoryHierarchy(ctx, cc.db, category); err != nil {
+		if services.IsCategoryHierarchyError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	category.ID = primitive.NewObjectID()
 	category.CreatedAt = time.Now()
-	result, err := cc.col.InsertOne(ctx, category)
+	category.DeletedAt = nil
+	category.Version = 0 // A new document starts at version 0, whatever the client sent
+	// The category and its audit entry are written in the same MongoDB transaction
+	err := utils.RunInTransaction(ctx, cc.db, func(sessCtx mongo.SessionContext) error {
+		if _, err := cc.col.InsertOne(sessCtx, category); err != nil {
+			return err
+		}
+		return services.RecordAudit(sessCtx, cc.db, services.AuditCategory, services.AuditCreate, category.ID, nil, category)
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"id": result.InsertedID})
+	c.JSON(http.StatusCreated, gin.H{"id": category.ID})
 }
 
 func (cc *CategoryController) UpdateCategory(c *gin.Context) {
@@ -86,26 +168,132 @@ func (cc *CategoryController) UpdateCategory(c *gin.Context) {
 		return
 	}
 
+	version, ok := expectedVersion(c)
+	if !ok {
+		return
+	}
 	var category models.Category
 	if err := c.ShouldBindBodyWithJSON(&category); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	existing, ok := cc.findForUpdate(c, ctx, id, version)
+	if !ok {
+		return
+	}
+
+	unset := bson.M{}
+	// Omitting parent_id moves the category to the top level
+	if category.ParentID.IsZero() {
+		unset["parent_id"] = ""
+	}
+	cc.save(c, ctx, existing, category, version, unset)
+}
+
+// PatchCategory changes only the fields sent, as an RFC 7396 merge patch: a null parent_id moves
+// the category to the top level
+func (cc *CategoryController) PatchCategory(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), cc.cfg.Timeouts.Request)
+	defer cancel()
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	version, ok := expectedVersion(c)
+	if !ok {
+		return
+	}
+	existing, ok := cc.findForUpdate(c, ctx, id, version)
+	if !ok {
+		return
+	}
+
+	var category models.Category
+	if !applyMergePatch(c, existing, &category) {
+		return
+	}
+	unset, err := utils.RemovedFields(existing, category)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	cc.save(c, ctx, existing, category, version, unset)
+}
+
+// findForUpdate loads the category an update is based on, answering 404 or 412 itself when it can't be updated
+func (cc *CategoryController) findForUpdate(c *gin.Context, ctx context.Context, id primitive.ObjectID, version int64) (models.Category, bool) {
+	var existing models.Category
+	if err := cc.col.FindOne(ctx, utils.NotDeleted(bson.M{"_id": id})).Decode(&existing); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+		return existing, false
+	}
+	if existing.Version != version {
+		staleVersion(c, existing.Version)
+		return existing, false
+	}
+	return existing, true
+}
+
+// save writes category over existing, at the version the client read, removing the fields in unset
+func (cc *CategoryController) save(c *gin.Context, ctx context.Context, existing, category models.Category, version int64, unset bson.M) {
+	id := existing.ID
+	category.ID = id
+	if err := services.ValidateCategoryHierarchy(ctx, cc.db, category); err != nil {
+		if services.IsCategoryHierarchyError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	category.CreatedAt = existing.CreatedAt
 	category.UpdatedAt = time.Now()
-	_, err = cc.col.UpdateOne(
-		ctx,
-		bson.M{"_id": id},
-		bson.M{"$set": category},
-	)
+	category.DeletedAt = nil
+	category.Version = 0 // Incremented by the update itself
+	update := utils.BumpVersion(bson.M{"$set": category})
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	var after models.Category
+	err := utils.RunInTransaction(ctx, cc.db, func(sessCtx mongo.SessionContext) error {
+		var before models.Category
+		// The version is checked and incremented by the same write
+		err := cc.col.FindOneAndUpdate(sessCtx, utils.MatchVersion(utils.NotDeleted(bson.M{"_id": id}), version), update).Decode(&before)
+		if err != nil {
+			return err
+		}
+		if err := cc.col.FindOne(sessCtx, bson.M{"_id": id}).Decode(&after); err != nil {
+			return err
+		}
+		return services.RecordAudit(sessCtx, cc.db, services.AuditCategory, services.AuditUpdate, id, before, after)
+	})
 	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			// Either gone or at another version than the client read
+			var current models.Category
+			if err := cc.col.FindOne(ctx, utils.NotDeleted(bson.M{"_id": id})).Decode(&current); err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+				return
+			}
+			staleVersion(c, current.Version)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "category updated"})
+	setETag(c, after.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "category updated", "version": after.Version})
 }
 
+// DeleteCategory deletes a category. While transactions, recurrences or subcategories use it the delete is refused
+// with 409, unless ?reassign_to=<category id> moves them to another category or ?cascade=true deletes them too.
+// Subcategories are never deleted; they move up to the deleted category's parent
 func (cc *CategoryController) DeleteCategory(c *gin.Context) {
 	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), cc.cfg.Timeouts.Request)
 	defer cancel()
@@ -116,11 +304,32 @@ func (cc *CategoryController) DeleteCategory(c *gin.Context) {
 		return
 	}
 
-	_, err = cc.col.DeleteOne(ctx, bson.M{"_id": id})
+	mode, target, err := services.ParseDeleteMode(c.Query("reassign_to"), c.Query("cascade"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The category and everything pointing at it change in the same MongoDB transaction
+	var usage services.ReferenceUsage
+	err = utils.RunInTransaction(ctx, cc.db, func(sessCtx mongo.SessionContext) error {
+		var err error
+		usage, err = services.DeleteCategory(sessCtx, cc.db, id, mode, target)
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+		case errors.Is(err, services.ErrReferenceInUse):
+			c.JSON(http.StatusConflict, gin.H{"error": "category " + err.Error(), "usage": usage})
+		case services.IsReferenceError(err):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "category deleted"})
+	c.JSON(http.StatusOK, gin.H{"message": "category deleted", "usage": usage})
 }
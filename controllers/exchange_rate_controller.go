@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/1v4n-ML/finance-tracker-api/config"
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/services"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ExchangeRateController struct {
+	db  *mongo.Database
+	col *mongo.Collection
+	cfg *config.Config
+}
+
+func NewExchangeRateController(db *mongo.Database, cfg *config.Config) *ExchangeRateController {
+	return &ExchangeRateController{
+		db:  db,
+		col: db.Collection("exchange_rates"),
+		cfg: cfg,
+	}
+}
+
+// GetAll returns the exchange rates, newest first. Optional filters: pair, start_date and end_date (DATE_LAYOUT)
+func (ec *ExchangeRateController) GetAll(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), ec.cfg.Timeouts.Request)
+	defer cancel()
+
+	filter := bson.M{}
+	if pair := c.Query("pair"); pair != "" {
+		filter["pair"] = strings.ToUpper(pair)
+	}
+	dateFilter := bson.M{}
+	if raw := c.Query("start_date"); raw != "" {
+		start, err := utils.ParseDateToISO(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_date: " + err.Error()})
+			return
+		}
+		dateFilter["$gte"] = start
+	}
+	if raw := c.Query("end_date"); raw != "" {
+		end, err := utils.ParseDateToISO(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "end_date: " + err.Error()})
+			return
+		}
+		dateFilter["$lte"] = end
+	}
+	if len(dateFilter) > 0 {
+		filter["date"] = dateFilter
+	}
+
+	cursor, err := ec.col.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "date", Value: -1}, {Key: "pair", Value: 1}}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err := cursor.Close(ctx)
+		if err != nil {
+
+		}
+	}(cursor, ctx)
+
+	rates := []models.ExchangeRate{}
+	if err = cursor.All(ctx, &rates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rates)
+}
+
+// Create stores the rate of a pair for a day, replacing the rate already stored for that day
+func (ec *ExchangeRateController) Create(c *gin.Context) {
+	var rate models.ExchangeRate
+	if err := c.ShouldBindJSON(&rate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), ec.cfg.Timeouts.Request)
+	defer cancel()
+
+	inserted, err := services.UpsertExchangeRate(ctx, ec.db, rate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if inserted {
+		c.JSON(http.StatusCreated, gin.H{"message": "exchange rate created"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "exchange rate updated"})
+}
+
+// Import loads a CSV file ("file" form field) with date,pair,rate lines
+func (ec *ExchangeRateController) Import(c *gin.Context) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing CSV file in 'file' field"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), ec.cfg.Timeouts.Request)
+	defer cancel()
+
+	report, err := services.ImportExchangeRatesCSV(ctx, ec.db, file)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// Delete removes an exchange rate
+func (ec *ExchangeRateController) Delete(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), ec.cfg.Timeouts.Request)
+	defer cancel()
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	_, err = ec.col.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "exchange rate deleted"})
+}
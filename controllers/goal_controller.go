@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/config"
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/services"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type GoalController struct {
+	db  *mongo.Database
+	col *mongo.Collection
+	cfg *config.Config
+}
+
+func NewGoalController(db *mongo.Database, cfg *config.Config) *GoalController {
+	return &GoalController{
+		db:  db,
+		col: db.Collection("goals"),
+		cfg: cfg,
+	}
+}
+
+// GetAll returns all goals
+func (gc *GoalController) GetAll(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), gc.cfg.Timeouts.Request)
+	defer cancel()
+
+	cursor, err := gc.col.Find(ctx, bson.M{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err := cursor.Close(ctx)
+		if err != nil {
+
+		}
+	}(cursor, ctx)
+
+	goals := []models.Goal{}
+	if err = cursor.All(ctx, &goals); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, goals)
+}
+
+// GetByID returns a single goal by ID
+func (gc *GoalController) GetByID(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), gc.cfg.Timeouts.Request)
+	defer cancel()
+
+	goal, ok := gc.findGoal(c, ctx)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, goal)
+}
+
+// Create adds a new goal
+func (gc *GoalController) Create(c *gin.Context) {
+	var goal models.Goal
+	if err := c.ShouldBindJSON(&goal); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), gc.cfg.Timeouts.Request)
+	defer cancel()
+
+	if err := gc.prepareGoal(ctx, &goal); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	goal.ID = primitive.NewObjectID()
+	goal.CreatedAt = time.Now()
+	result, err := gc.col.InsertOne(ctx, goal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": result.InsertedID})
+}
+
+// Update modifies an existing goal
+func (gc *GoalController) Update(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), gc.cfg.Timeouts.Request)
+	defer cancel()
+
+	existing, ok := gc.findGoal(c, ctx)
+	if !ok {
+		return
+	}
+
+	var goal models.Goal
+	if err := c.ShouldBindJSON(&goal); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The starting balance is only taken again when the goal is linked to another account
+	if goal.AccountID == existing.AccountID {
+		goal.InitialAmount = existing.InitialAmount
+	} else if err := gc.prepareGoal(ctx, &goal); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	goal.ID = existing.ID
+	goal.CreatedAt = existing.CreatedAt
+	goal.UpdatedAt = time.Now()
+	_, err := gc.col.ReplaceOne(ctx, bson.M{"_id": existing.ID}, goal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "goal updated"})
+}
+
+// Delete removes a goal. Tagged transactions keep their goal_id
+func (gc *GoalController) Delete(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), gc.cfg.Timeouts.Request)
+	defer cancel()
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	_, err = gc.col.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "goal deleted"})
+}
+
+// Progress returns the goal progress, the monthly contribution needed and the projected completion date
+func (gc *GoalController) Progress(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), gc.cfg.Timeouts.Request)
+	defer cancel()
+
+	goal, ok := gc.findGoal(c, ctx)
+	if !ok {
+		return
+	}
+
+	progress, err := services.ComputeGoalProgress(ctx, gc.db, goal, time.Now())
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "linked account not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, progress)
+}
+
+func (gc *GoalController) findGoal(c *gin.Context, ctx context.Context) (models.Goal, bool) {
+	var goal models.Goal
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return goal, false
+	}
+
+	if err := gc.col.FindOne(ctx, bson.M{"_id": id}).Decode(&goal); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+		return goal, false
+	}
+	return goal, true
+}
+
+// prepareGoal checks the linked account and records its balance as the starting point of the goal
+func (gc *GoalController) prepareGoal(ctx context.Context, goal *models.Goal) error {
+	goal.InitialAmount = 0
+	if goal.AccountID.IsZero() {
+		return nil
+	}
+	var account models.Account
+	if err := gc.db.Collection("accounts").FindOne(ctx, utils.NotDeleted(bson.M{"_id": goal.AccountID})).Decode(&account); err != nil {
+		return errors.New("linked account not found")
+	}
+	goal.InitialAmount = account.Balance
+	return nil
+}
@@ -0,0 +1,251 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/config"
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/services"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ImportController struct {
+	db       *mongo.Database
+	profiles *mongo.Collection
+	cfg      *config.Config
+}
+
+func NewImportController(db *mongo.Database, cfg *config.Config) *ImportController {
+	return &ImportController{
+		db:       db,
+		profiles: db.Collection("import_profiles"),
+		cfg:      cfg,
+	}
+}
+
+// OFX imports an OFX 1.x or 2.x statement ("file" form field) into the account given by account_id
+func (ic *ImportController) OFX(c *gin.Context) {
+	accountID, err := primitive.ObjectIDFromHex(c.Query("account_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or missing account_id"})
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing OFX file in 'file' field"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), ic.cfg.Timeouts.Request)
+	defer cancel()
+
+	report, err := services.ImportOFX(ctx, ic.db, accountID, file, ic.cfg)
+	if err != nil {
+		if errors.Is(err, services.ErrImportAccountNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// CSV runs a CSV file ("file" form field) through the import profile given by profile_id.
+// account_id overrides the profile's default account. Nothing is written unless commit=true,
+// so the first call returns a preview of what would be created and skipped
+func (ic *ImportController) CSV(c *gin.Context) {
+	profileID, err := primitive.ObjectIDFromHex(c.Query("profile_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or missing profile_id"})
+		return
+	}
+	var accountID primitive.ObjectID
+	if raw := c.Query("account_id"); raw != "" {
+		if accountID, err = primitive.ObjectIDFromHex(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account_id"})
+			return
+		}
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing CSV file in 'file' field"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), ic.cfg.Timeouts.Request)
+	defer cancel()
+
+	var profile models.ImportProfile
+	if err := ic.profiles.FindOne(ctx, bson.M{"_id": profileID}).Decode(&profile); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import profile not found"})
+		return
+	}
+
+	report, err := services.ImportCSV(ctx, ic.db, profile, accountID, file, ic.cfg, c.Query("commit") == "true")
+	if err != nil {
+		if errors.Is(err, services.ErrImportAccountNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetProfiles returns all CSV import profiles
+func (ic *ImportController) GetProfiles(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), ic.cfg.Timeouts.Request)
+	defer cancel()
+
+	cursor, err := ic.profiles.Find(ctx, bson.M{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err := cursor.Close(ctx)
+		if err != nil {
+
+		}
+	}(cursor, ctx)
+
+	profiles := []models.ImportProfile{}
+	if err = cursor.All(ctx, &profiles); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, profiles)
+}
+
+// GetProfile returns a single CSV import profile by ID
+func (ic *ImportController) GetProfile(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), ic.cfg.Timeouts.Request)
+	defer cancel()
+
+	profile, ok := ic.findProfile(c, ctx)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// CreateProfile adds a new CSV import profile
+func (ic *ImportController) CreateProfile(c *gin.Context) {
+	var profile models.ImportProfile
+	if err := c.ShouldBindJSON(&profile); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ValidateImportProfile(profile); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), ic.cfg.Timeouts.Request)
+	defer cancel()
+
+	profile.ID = primitive.NewObjectID()
+	profile.CreatedAt = time.Now()
+	result, err := ic.profiles.InsertOne(ctx, profile)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": result.InsertedID})
+}
+
+// UpdateProfile modifies an existing CSV import profile
+func (ic *ImportController) UpdateProfile(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), ic.cfg.Timeouts.Request)
+	defer cancel()
+
+	existing, ok := ic.findProfile(c, ctx)
+	if !ok {
+		return
+	}
+
+	var profile models.ImportProfile
+	if err := c.ShouldBindJSON(&profile); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ValidateImportProfile(profile); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	profile.ID = existing.ID
+	profile.CreatedAt = existing.CreatedAt
+	profile.UpdatedAt = time.Now()
+	_, err := ic.profiles.ReplaceOne(ctx, bson.M{"_id": existing.ID}, profile)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "import profile updated"})
+}
+
+// DeleteProfile removes a CSV import profile
+func (ic *ImportController) DeleteProfile(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), ic.cfg.Timeouts.Request)
+	defer cancel()
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	_, err = ic.profiles.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "import profile deleted"})
+}
+
+func (ic *ImportController) findProfile(c *gin.Context, ctx context.Context) (models.ImportProfile, bool) {
+	var profile models.ImportProfile
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return profile, false
+	}
+
+	if err := ic.profiles.FindOne(ctx, bson.M{"_id": id}).Decode(&profile); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import profile not found"})
+		return profile, false
+	}
+	return profile, true
+}
@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"net/http"
+	"reflect"
+
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// parsePage reads the sort, limit and cursor query parameters, answering 400 itself when they're invalid
+func parsePage(c *gin.Context, sortable map[string]string, defaultSort string) (utils.Page, bool) {
+	page, err := utils.ParsePage(c.Query("sort"), c.Query("limit"), c.Query("cursor"), sortable, defaultSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return page, false
+	}
+	return page, true
+}
+
+// respondPage answers with a page of items, a slice read with page.FindOptions. The document past
+// the limit is only there to tell that a next page exists, so it's dropped and a cursor is sent instead
+func respondPage(c *gin.Context, page utils.Page, items interface{}) {
+	list := reflect.ValueOf(items)
+	response := models.Page{Items: items}
+	if int64(list.Len()) > page.Limit {
+		list = list.Slice(0, int(page.Limit))
+		cursor, err := page.Cursor(list.Index(list.Len() - 1).Interface())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		response.Items = list.Interface()
+		response.NextCursor = &cursor
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// mergePatchContentType is the media type of RFC 7396 patches. Plain application/json is accepted too
+const mergePatchContentType = "application/merge-patch+json"
+
+// serverManagedFields can't be patched on any document
+var serverManagedFields = []string{"id", "_id", "created_at", "updated_at", "deleted_at"}
+
+// readBody returns the request body, caching it where ShouldBindBodyWithJSON looks, so the version can
+// still be read from it
+func readBody(c *gin.Context) ([]byte, error) {
+	if cached, ok := c.Get(gin.BodyBytesKey); ok {
+		if body, ok := cached.([]byte); ok {
+			return body, nil
+		}
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.Set(gin.BodyBytesKey, body)
+	return body, nil
+}
+
+// applyMergePatch applies the merge patch in the body to current and decodes the result into patched.
+// Fields in readOnly, or unknown to the model, are refused, and only the fields the patch sets are
+// validated. A version member is the precondition read by expectedVersion, not a change.
+// Writes the error response itself and returns false when the patch can't be applied
+func applyMergePatch(c *gin.Context, current, patched interface{}, readOnly ...string) bool {
+	contentType := c.ContentType()
+	if contentType != mergePatchContentType && contentType != binding.MIMEJSON {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "send the patch as " + mergePatchContentType})
+		return false
+	}
+	body, err := readBody(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the patch must be a JSON object"})
+		return false
+	}
+	delete(patch, "version")
+
+	fields := jsonFields(patched)
+	readOnly = append(readOnly, serverManagedFields...)
+	validated := make([]string, 0, len(patch))
+	for key := range patch {
+		for _, field := range readOnly {
+			if key == field {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s is managed by the server and can't be patched", key)})
+				return false
+			}
+		}
+		name, known := fields[key]
+		if !known {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown field %s", key)})
+			return false
+		}
+		validated = append(validated, name)
+	}
+
+	document, err := json.Marshal(current)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	changes, err := json.Marshal(patch)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	merged, err := utils.MergePatch(document, changes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if err := json.Unmarshal(merged, patched); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	// The binding tags of the untouched fields were checked when they were written
+	if engine, ok := binding.Validator.Engine().(*validator.Validate); ok && len(validated) > 0 {
+		if err := engine.StructPartial(patched, validated...); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return false
+		}
+	}
+	return true
+}
+
+// jsonFields maps the JSON names of a model's fields to the Go names the validator uses
+func jsonFields(model interface{}) map[string]string {
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	fields := map[string]string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Name
+	}
+	return fields
+}
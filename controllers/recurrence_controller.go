@@ -0,0 +1,263 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/config"
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/services"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const defaultPreviewCount = 12
+
+var errEndBeforeStart = errors.New("schedule end_date must not be before start_date")
+
+type RecurrenceController struct {
+	db  *mongo.Database
+	col *mongo.Collection
+	cfg *config.Config
+}
+
+func NewRecurrenceController(db *mongo.Database, cfg *config.Config) *RecurrenceController {
+	return &RecurrenceController{
+		db:  db,
+		col: db.Collection("recurrences"),
+		cfg: cfg,
+	}
+}
+
+// GetAll returns all recurrences
+func (rc *RecurrenceController) GetAll(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), rc.cfg.Timeouts.Request)
+	defer cancel()
+
+	cursor, err := rc.col.Find(ctx, bson.M{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err := cursor.Close(ctx)
+		if err != nil {
+
+		}
+	}(cursor, ctx)
+
+	recurrences := []models.Recurrence{}
+	if err = cursor.All(ctx, &recurrences); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, recurrences)
+}
+
+// GetByID returns a single recurrence by ID
+func (rc *RecurrenceController) GetByID(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), rc.cfg.Timeouts.Request)
+	defer cancel()
+
+	recurrence, ok := rc.findRecurrence(c, ctx)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, recurrence)
+}
+
+// Create adds a new recurrence
+func (rc *RecurrenceController) Create(c *gin.Context) {
+	var recurrence models.Recurrence
+	if err := c.ShouldBindJSON(&recurrence); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateRecurrence(recurrence); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), rc.cfg.Timeouts.Request)
+	defer cancel()
+
+	recurrence.ID = primitive.NewObjectID()
+	recurrence.LastOccurrence = nil
+	recurrence.CreatedAt = time.Now()
+	result, err := rc.col.InsertOne(ctx, recurrence)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": result.InsertedID})
+}
+
+// Update modifies an existing recurrence. Occurrences already materialized are kept
+func (rc *RecurrenceController) Update(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), rc.cfg.Timeouts.Request)
+	defer cancel()
+
+	existing, ok := rc.findRecurrence(c, ctx)
+	if !ok {
+		return
+	}
+
+	var recurrence models.Recurrence
+	if err := c.ShouldBindJSON(&recurrence); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateRecurrence(recurrence); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recurrence.ID = existing.ID
+	recurrence.LastOccurrence = existing.LastOccurrence
+	recurrence.CreatedAt = existing.CreatedAt
+	recurrence.UpdatedAt = time.Now()
+	_, err := rc.col.ReplaceOne(ctx, bson.M{"_id": existing.ID}, recurrence)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "recurrence updated"})
+}
+
+// Delete removes a recurrence. Transactions it already created are kept
+func (rc *RecurrenceController) Delete(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), rc.cfg.Timeouts.Request)
+	defer cancel()
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	_, err = rc.col.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "recurrence deleted"})
+}
+
+// Preview returns the next occurrences of a recurrence as the transactions they would create
+func (rc *RecurrenceController) Preview(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), rc.cfg.Timeouts.Request)
+	defer cancel()
+
+	recurrence, ok := rc.findRecurrence(c, ctx)
+	if !ok {
+		return
+	}
+
+	count := defaultPreviewCount
+	if raw := c.Query("count"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "count must be a positive integer"})
+			return
+		}
+		count = parsed
+	}
+
+	from := time.Now()
+	if recurrence.LastOccurrence != nil && recurrence.LastOccurrence.After(from) {
+		from = *recurrence.LastOccurrence
+	}
+	upcoming := []models.Transaction{}
+	for _, date := range services.Occurrences(recurrence.Schedule, from, time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC), count) {
+		upcoming = append(upcoming, services.TransactionFromRecurrence(recurrence, date))
+	}
+
+	c.JSON(http.StatusOK, upcoming)
+}
+
+// Upcoming returns the occurrences of every active recurrence due in the next `days` days (default 30)
+func (rc *RecurrenceController) Upcoming(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), rc.cfg.Timeouts.Request)
+	defer cancel()
+
+	days := 30
+	if raw := c.Query("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be a positive integer"})
+			return
+		}
+		days = parsed
+	}
+
+	cursor, err := rc.col.Find(ctx, bson.M{"paused": bson.M{"$ne": true}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var recurrences []models.Recurrence
+	if err = cursor.All(ctx, &recurrences); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	until := now.AddDate(0, 0, days)
+	upcoming := []models.Transaction{}
+	for _, recurrence := range recurrences {
+		for _, date := range services.Occurrences(recurrence.Schedule, now, until, 0) {
+			upcoming = append(upcoming, services.TransactionFromRecurrence(recurrence, date))
+		}
+	}
+
+	c.JSON(http.StatusOK, upcoming)
+}
+
+// Run materializes every due occurrence right away instead of waiting for the scheduler
+func (rc *RecurrenceController) Run(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), rc.cfg.Timeouts.Request)
+	defer cancel()
+
+	created, err := services.MaterializeRecurrencesService(rc.db, ctx, rc.cfg.Currency.Base)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "created": created})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"created": created})
+}
+
+func (rc *RecurrenceController) findRecurrence(c *gin.Context, ctx context.Context) (models.Recurrence, bool) {
+	var recurrence models.Recurrence
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return recurrence, false
+	}
+
+	if err := rc.col.FindOne(ctx, bson.M{"_id": id}).Decode(&recurrence); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recurrence not found"})
+		return recurrence, false
+	}
+	return recurrence, true
+}
+
+func validateRecurrence(recurrence models.Recurrence) error {
+	if recurrence.Schedule.EndDate != nil && recurrence.Schedule.EndDate.Before(recurrence.Schedule.StartDate) {
+		return errEndBeforeStart
+	}
+	// The template must make a valid transaction on its own
+	return utils.ValidateTransaction(services.TransactionFromRecurrence(recurrence, recurrence.Schedule.StartDate))
+}
@@ -71,6 +71,7 @@ func (rc *ReportsController) AggregateTransactions(c *gin.Context) {
 	if results == nil {
 		results = []bson.M{}
 	}
+	services.FormatMoneyMetrics(req, results)
 
 	c.JSON(200, results)
 }
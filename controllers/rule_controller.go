@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/config"
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/services"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RuleController struct {
+	db  *mongo.Database
+	col *mongo.Collection
+	cfg *config.Config
+}
+
+func NewRuleController(db *mongo.Database, cfg *config.Config) *RuleController {
+	return &RuleController{
+		db:  db,
+		col: db.Collection("rules"),
+		cfg: cfg,
+	}
+}
+
+// GetAll returns all rules in the order they run
+func (rc *RuleController) GetAll(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), rc.cfg.Timeouts.Request)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "priority", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := rc.col.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err := cursor.Close(ctx)
+		if err != nil {
+
+		}
+	}(cursor, ctx)
+
+	rules := []models.Rule{}
+	if err = cursor.All(ctx, &rules); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// GetByID returns a single rule by ID
+func (rc *RuleController) GetByID(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), rc.cfg.Timeouts.Request)
+	defer cancel()
+
+	rule, ok := rc.findRule(c, ctx)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// Create adds a new rule
+func (rc *RuleController) Create(c *gin.Context) {
+	var rule models.Rule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ValidateRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), rc.cfg.Timeouts.Request)
+	defer cancel()
+
+	rule.ID = primitive.NewObjectID()
+	rule.CreatedAt = time.Now()
+	result, err := rc.col.InsertOne(ctx, rule)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": result.InsertedID})
+}
+
+// Update modifies an existing rule
+func (rc *RuleController) Update(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), rc.cfg.Timeouts.Request)
+	defer cancel()
+
+	existing, ok := rc.findRule(c, ctx)
+	if !ok {
+		return
+	}
+
+	var rule models.Rule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ValidateRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule.ID = existing.ID
+	rule.CreatedAt = existing.CreatedAt
+	rule.UpdatedAt = time.Now()
+	_, err := rc.col.ReplaceOne(ctx, bson.M{"_id": existing.ID}, rule)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "rule updated"})
+}
+
+// Delete removes a rule
+func (rc *RuleController) Delete(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), rc.cfg.Timeouts.Request)
+	defer cancel()
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	_, err = rc.col.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "rule deleted"})
+}
+
+// Run applies the rules to the existing transactions and returns the changes as a diff.
+// Nothing is written unless commit=true. overwrite=true lets rules replace categories already set.
+// Optional filters: account_id, start_date and end_date (DATE_LAYOUT)
+func (rc *RuleController) Run(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), rc.cfg.Timeouts.Request)
+	defer cancel()
+
+	filter := bson.M{}
+	if raw := c.Query("account_id"); raw != "" {
+		accountID, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account_id"})
+			return
+		}
+		filter["account_id"] = accountID
+	}
+	dateFilter := bson.M{}
+	if raw := c.Query("start_date"); raw != "" {
+		start, err := utils.ParseDateToISO(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_date: " + err.Error()})
+			return
+		}
+		dateFilter["$gte"] = start
+	}
+	if raw := c.Query("end_date"); raw != "" {
+		end, err := utils.ParseDateToISO(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "end_date: " + err.Error()})
+			return
+		}
+		dateFilter["$lt"] = end.AddDate(0, 0, 1)
+	}
+	if len(dateFilter) > 0 {
+		filter["date"] = dateFilter
+	}
+
+	commit := c.Query("commit") == "true"
+	changes, err := services.RunRules(ctx, rc.db, filter, c.Query("overwrite") == "true", commit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"dry_run": !commit, "changes": changes})
+}
+
+func (rc *RuleController) findRule(c *gin.Context, ctx context.Context) (models.Rule, bool) {
+	var rule models.Rule
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return rule, false
+	}
+
+	if err := rc.col.FindOne(ctx, bson.M{"_id": id}).Decode(&rule); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rule not found"})
+		return rule, false
+	}
+	return rule, true
+}
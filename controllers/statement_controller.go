@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/config"
+	"github.com/1v4n-ML/finance-tracker-api/services"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type StatementController struct {
+	db  *mongo.Database
+	cfg *config.Config
+}
+
+func NewStatementController(db *mongo.Database, cfg *config.Config) *StatementController {
+	return &StatementController{
+		db:  db,
+		cfg: cfg,
+	}
+}
+
+// GetStatements returns every billing cycle of a credit card account, newest first
+func (sc *StatementController) GetStatements(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), sc.cfg.Timeouts.Request)
+	defer cancel()
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	statements, err := services.BuildStatements(ctx, sc.db, id, time.Now())
+	if err != nil {
+		sc.handleError(c, err)
+		return
+	}
+
+	// The list only carries the totals, the transactions are available per period
+	for i := range statements {
+		statements[i].Transactions = nil
+	}
+
+	c.JSON(http.StatusOK, statements)
+}
+
+// GetStatementByPeriod returns a single billing cycle (YYYY-MM) with its transactions
+func (sc *StatementController) GetStatementByPeriod(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), sc.cfg.Timeouts.Request)
+	defer cancel()
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	period := c.Param("period")
+	if _, err := time.Parse(services.StatementPeriodLayout, period); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period, expected YYYY-MM"})
+		return
+	}
+
+	statement, err := services.BuildStatement(ctx, sc.db, id, period, time.Now())
+	if err != nil {
+		sc.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statement)
+}
+
+func (sc *StatementController) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+	case errors.Is(err, services.ErrNotCreditCard):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/1v4n-ML/finance-tracker-api/config"
+	"github.com/1v4n-ML/finance-tracker-api/services"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type TagController struct {
+	db  *mongo.Database
+	cfg *config.Config
+}
+
+func NewTagController(db *mongo.Database, cfg *config.Config) *TagController {
+	return &TagController{
+		db:  db,
+		cfg: cfg,
+	}
+}
+
+// GetAll returns the tags in use with how many transactions carry each one
+func (tc *TagController) GetAll(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), tc.cfg.Timeouts.Request)
+	defer cancel()
+
+	tags, err := services.ListTags(ctx, tc.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// renameTagRequest is the body of Rename
+type renameTagRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// Rename renames a tag on every transaction. Renaming to a tag already in use merges them.
+// The route takes the rest of the path as the tag, so tags holding a "/" can be renamed too
+func (tc *TagController) Rename(c *gin.Context) {
+	var request renameTagRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), tc.cfg.Timeouts.Request)
+	defer cancel()
+
+	tag := strings.TrimPrefix(c.Param("tag"), "/")
+	modified, err := services.MergeTags(ctx, tc.db, []string{tag}, request.Name)
+	if err != nil {
+		tc.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "tag renamed", "transactions_updated": modified})
+}
+
+// mergeTagsRequest is the body of Merge
+type mergeTagsRequest struct {
+	Sources []string `json:"sources" binding:"required,min=1"`
+	Target  string   `json:"target" binding:"required"`
+}
+
+// Merge replaces the source tags with the target tag on every transaction
+func (tc *TagController) Merge(c *gin.Context) {
+	var request mergeTagsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), tc.cfg.Timeouts.Request)
+	defer cancel()
+
+	modified, err := services.MergeTags(ctx, tc.db, request.Sources, request.Target)
+	if err != nil {
+		tc.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "tags merged", "transactions_updated": modified})
+}
+
+func (tc *TagController) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrTagsRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
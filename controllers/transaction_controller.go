@@ -2,12 +2,17 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/1v4n-ML/finance-tracker-api/config"
 	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/services"
 	"github.com/1v4n-ML/finance-tracker-api/utils"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
@@ -29,33 +34,85 @@ func NewTransactionController(db *mongo.Database, cfg *config.Config) *Transacti
 	}
 }
 
-// GetAll returns all transactions
+// transactionSorts are the sort query values of GetAll
+var transactionSorts = map[string]string{"date": "date", "amount": "amount", "description": "description"}
+
+// GetAll returns a page of transactions, newest first unless sort says otherwise. Filters: start_date,
+// end_date, account_id (either side of a transfer), category_id (including splits), type, min_amount,
+// max_amount, description (case-insensitive substring) and tag
 func (tc *TransactionController) GetAll(c *gin.Context) {
 	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), tc.cfg.Timeouts.Request)
 	defer cancel()
 
-	startDate, err := utils.ParseDateToISO(c.Query("start_date"))
-	if err != nil {
-		//dunno what to do
-	}
-	endDate, err := utils.ParseDateToISO(c.Query("end_date"))
-	if err != nil {
-		//dunno what to do
+	page, ok := parsePage(c, transactionSorts, "-date")
+	if !ok {
+		return
 	}
 
-	var cursor *mongo.Cursor
-	if startDate.IsZero() && endDate.IsZero() {
-		cursor, err = tc.col.Find(ctx, bson.M{})
+	filter := bson.M{}
+	and := bson.A{}
+	date := bson.M{}
+	for param, op := range map[string]string{"start_date": "$gte", "end_date": "$lte"} {
+		if value := c.Query(param); value != "" {
+			d, err := utils.ParseDateToISO(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + ": " + err.Error()})
+				return
+			}
+			date[op] = d
+		}
+	}
+	if len(date) > 0 {
+		filter["date"] = date
+	}
+	if value := c.Query("account_id"); value != "" {
+		id, err := primitive.ObjectIDFromHex(value)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "query fucked up smh"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account_id"})
 			return
 		}
-	} else {
-		cursor, err = tc.col.Find(ctx, bson.M{"date": bson.M{"$gte": startDate, "$lte": endDate}})
+		and = append(and, bson.M{"$or": bson.A{bson.M{"account_id": id}, bson.M{"destination_account_id": id}}})
+	}
+	if value := c.Query("category_id"); value != "" {
+		id, err := primitive.ObjectIDFromHex(value)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "query fucked up smh"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category_id"})
 			return
 		}
+		and = append(and, bson.M{"$or": bson.A{bson.M{"category_id": id}, bson.M{"splits.category_id": id}}})
+	}
+	if value := c.Query("type"); value != "" {
+		filter["type"] = value
+	}
+	amount := bson.M{}
+	for param, op := range map[string]string{"min_amount": "$gte", "max_amount": "$lte"} {
+		if value := c.Query(param); value != "" {
+			m, err := models.ParseMoney(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + ": " + err.Error()})
+				return
+			}
+			amount[op] = m
+		}
+	}
+	if len(amount) > 0 {
+		filter["amount"] = amount
+	}
+	if value := c.Query("description"); value != "" {
+		filter["description"] = bson.M{"$regex": regexp.QuoteMeta(value), "$options": "i"}
+	}
+	// tag=a&tag=b returns the transactions carrying all of them
+	if tags := utils.NormalizeTags(c.QueryArray("tag")); len(tags) > 0 {
+		filter["tags"] = bson.M{"$all": tags}
+	}
+	if len(and) > 0 {
+		filter["$and"] = and
+	}
+
+	cursor, err := tc.col.Find(ctx, page.Filter(utils.NotDeleted(filter)), page.FindOptions())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
 	defer func(cursor *mongo.Cursor, ctx context.Context) {
@@ -65,13 +122,39 @@ func (tc *TransactionController) GetAll(c *gin.Context) {
 		}
 	}(cursor, ctx)
 
-	var transactions []models.Transaction
+	transactions := []models.Transaction{}
 	if err = cursor.All(ctx, &transactions); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, transactions)
+	respondPage(c, page, transactions)
+}
+
+// Search returns a page of the transactions whose description or tags match q, most relevant first.
+// Words are matched by their Portuguese stem, ignoring case and accents
+func (tc *TransactionController) Search(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	page, err := utils.ParsePage(services.SearchSort, c.Query("limit"), c.Query("cursor"), services.SearchSorts, services.SearchSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), tc.cfg.Timeouts.Request)
+	defer cancel()
+
+	results, err := services.SearchTransactions(ctx, tc.db, query, page)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondPage(c, page, results)
 }
 
 // GetByID returns a single transaction by ID
@@ -86,16 +169,59 @@ func (tc *TransactionController) GetByID(c *gin.Context) {
 	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), tc.cfg.Timeouts.Request)
 	defer cancel()
 
-	err = tc.col.FindOne(ctx, bson.M{"_id": id}).Decode(&transaction)
+	err = tc.col.FindOne(ctx, utils.NotDeleted(bson.M{"_id": id})).Decode(&transaction)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
 		return
 	}
 
+	setETag(c, transaction.Version)
 	c.JSON(http.StatusOK, transaction)
 }
 
-// Create adds a new transaction
+// suggestCategoryRequest is the body of SuggestCategory
+type suggestCategoryRequest struct {
+	Description string `json:"description" binding:"required"`
+	Type        string `json:"type" binding:"omitempty,oneof=income expense"`
+	Limit       int    `json:"limit" binding:"omitempty,min=1"`
+}
+
+// SuggestCategory ranks the categories past transactions with a similar description were given.
+// auto_apply tells whether the best one is confident enough to be applied on creation
+func (tc *TransactionController) SuggestCategory(c *gin.Context) {
+	var request suggestCategoryRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Limit == 0 {
+		request.Limit = 5
+	}
+
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), tc.cfg.Timeouts.Request)
+	defer cancel()
+
+	suggestions, err := services.SuggestCategories(ctx, tc.db, request.Description, request.Type, request.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Decided like on creation, training minimums included
+	categorizer, err := services.LoadCategorizer(ctx, tc.db, tc.cfg.Classifier.AutoApplyThreshold)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	_, autoApply, err := categorizer.Pick(ctx, request.Description, request.Type)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"suggestions": suggestions, "auto_apply": autoApply})
+}
+
+// Create adds a new transaction. It answers 409 with the likely duplicates unless force=true
 func (tc *TransactionController) Create(c *gin.Context) {
 	var transaction models.Transaction
 	if err := c.ShouldBindJSON(&transaction); err != nil {
@@ -103,23 +229,116 @@ func (tc *TransactionController) Create(c *gin.Context) {
 		log.Default().Println(err.Error())
 		return
 	}
+	if err := utils.ValidateTransaction(transaction); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	transaction.Tags = utils.NormalizeTags(transaction.Tags)
+	transaction.DeletedAt = nil // Only delete and restore move transactions in and out of the trash
+	transaction.Version = 0     // A new document starts at version 0, whatever the client sent
 
 	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), tc.cfg.Timeouts.Request)
 	defer cancel()
 
+	if err := services.ApplyAccountCurrency(ctx, tc.db, &transaction, tc.cfg.Currency.Base); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Categorization rules fill in what the client left out, then the learned suggestions
+	if err := services.ApplyRules(ctx, tc.db, &transaction); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.AutoCategorize(ctx, tc.db, &transaction, tc.cfg.Classifier.AutoApplyThreshold); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Installment purchases are expanded into one linked transaction per month
+	if transaction.InstallmentPlan != nil {
+		tc.createInstallments(c, ctx, transaction)
+		return
+	}
+
+	if err := services.AssignStatementPeriod(ctx, tc.db, &transaction); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A likely duplicate of a stored transaction is refused unless the client forces it
+	if c.Query("force") != "true" {
+		duplicates, err := services.FindDuplicatesOf(ctx, tc.db, transaction, services.DefaultDuplicateWindowDays, services.DefaultDuplicateMinScore)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(duplicates) > 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":      "possible duplicate transaction, send force=true to insert it anyway",
+				"duplicates": duplicates,
+			})
+			return
+		}
+	}
+
 	transaction.ID = primitive.NewObjectID()
 	transaction.CreatedAt = time.Now()
-	result, err := tc.col.InsertOne(ctx, transaction)
+
+	// The insert and the balance changes happen in the same MongoDB transaction
+	err := utils.RunInTransaction(ctx, tc.db, func(sessCtx mongo.SessionContext) error {
+		if err := services.InsertTransaction(sessCtx, tc.db, transaction); err != nil {
+			return err
+		}
+		return services.RecordAudit(sessCtx, tc.db, services.AuditTransaction, services.AuditCreate, transaction.ID, nil, transaction)
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		log.Default().Println(err.Error())
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"id": result.InsertedID})
+	c.JSON(http.StatusCreated, gin.H{"id": transaction.ID})
 }
 
-// Update modifies an existing transaction
+func (tc *TransactionController) createInstallments(c *gin.Context, ctx context.Context, transaction models.Transaction) {
+	installments, err := services.BuildInstallments(transaction)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(installments))
+	err = utils.RunInTransaction(ctx, tc.db, func(sessCtx mongo.SessionContext) error {
+		for i := range installments {
+			if err := services.AssignStatementPeriod(sessCtx, tc.db, &installments[i]); err != nil {
+				return err
+			}
+			if err := services.InsertTransaction(sessCtx, tc.db, installments[i]); err != nil {
+				return err
+			}
+			if err := services.RecordAudit(sessCtx, tc.db, services.AuditTransaction, services.AuditCreate, installments[i].ID, nil, installments[i]); err != nil {
+				return err
+			}
+			ids = append(ids, installments[i].ID)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		log.Default().Println(err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":                   ids[0],
+		"ids":                  ids,
+		"installment_group_id": installments[0].InstallmentGroupID,
+	})
+}
+
+// Update replaces an existing transaction. The client sends the version it read, as If-Match or in the body,
+// and gets 412 when the transaction changed since
 func (tc *TransactionController) Update(c *gin.Context) {
 	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
@@ -127,8 +346,12 @@ func (tc *TransactionController) Update(c *gin.Context) {
 		return
 	}
 
+	version, ok := expectedVersion(c)
+	if !ok {
+		return
+	}
 	var transaction models.Transaction
-	if err := c.ShouldBindJSON(&transaction); err != nil {
+	if err := c.ShouldBindBodyWithJSON(&transaction); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -136,18 +359,145 @@ func (tc *TransactionController) Update(c *gin.Context) {
 	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), tc.cfg.Timeouts.Request)
 	defer cancel()
 
+	var existing models.Transaction
+	if err := tc.col.FindOne(ctx, utils.NotDeleted(bson.M{"_id": id})).Decode(&existing); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+	if existing.Version != version {
+		staleVersion(c, existing.Version)
+		return
+	}
+
+	tc.save(c, ctx, existing, transaction, version, false)
+}
+
+// Patch changes only the fields sent, as an RFC 7396 merge patch: null removes a field.
+// Like Update it needs the version the patch is based on
+func (tc *TransactionController) Patch(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	version, ok := expectedVersion(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), tc.cfg.Timeouts.Request)
+	defer cancel()
+
+	var existing models.Transaction
+	if err := tc.col.FindOne(ctx, utils.NotDeleted(bson.M{"_id": id})).Decode(&existing); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+	if existing.Version != version {
+		staleVersion(c, existing.Version)
+		return
+	}
+
+	var transaction models.Transaction
+	// Installments and recurrences keep their links to the group or rule that created them
+	if !applyMergePatch(c, existing, &transaction, "installment_group_id", "installment_number", "installment_count", "recurrence_id") {
+		return
+	}
+
+	tc.save(c, ctx, existing, transaction, version, true)
+}
+
+// save writes transaction over existing, at the version the client read, and answers the request.
+// A partial update also removes the fields transaction no longer has; a full one only drops the splits
+func (tc *TransactionController) save(c *gin.Context, ctx context.Context, existing, transaction models.Transaction, version int64, partial bool) {
+	id := existing.ID
+	if err := utils.ValidateTransaction(transaction); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if transaction.InstallmentPlan != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "installment_plan is only accepted on creation"})
+		return
+	}
+	transaction.ID = id
+	transaction.Tags = utils.NormalizeTags(transaction.Tags)
+	transaction.CreatedAt = existing.CreatedAt
+	transaction.DeletedAt = nil // Only delete and restore move transactions in and out of the trash
+	transaction.Version = 0     // Incremented by the update itself
+
+	if err := services.ApplyAccountCurrency(ctx, tc.db, &transaction, tc.cfg.Currency.Base); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.AssignStatementPeriod(ctx, tc.db, &transaction); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	transaction.UpdatedAt = time.Now()
-	_, err = tc.col.UpdateOne(
-		ctx,
-		bson.M{"_id": id},
-		bson.M{"$set": transaction},
-	)
+
+	// cascade=true propagates the change to the installments after this one
+	cascade := c.Query("cascade") == "true" && !existing.InstallmentGroupID.IsZero()
+	updatedInstallments := 0
+	var updated models.Transaction
+	update := bson.M{"$set": transaction}
+	if partial {
+		unset, err := utils.RemovedFields(existing, transaction)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+	} else if len(transaction.Splits) == 0 && len(existing.Splits) > 0 {
+		// A PUT without splits turns a split transaction back into a single-category one
+		update["$unset"] = bson.M{"splits": ""}
+	}
+	err := utils.RunInTransaction(ctx, tc.db, func(sessCtx mongo.SessionContext) error {
+		// Moves the balances from the old version to the new one, including account and amount changes.
+		// The version is checked and incremented by the same write
+		before, after, err := services.UpdateTransactionAtVersion(sessCtx, tc.db, id, version, update)
+		if err != nil {
+			return err
+		}
+		updated = after
+		if err := services.RecordAudit(sessCtx, tc.db, services.AuditTransaction, services.AuditUpdate, id, before, after); err != nil {
+			return err
+		}
+		if !cascade {
+			return nil
+		}
+		transaction.InstallmentGroupID = existing.InstallmentGroupID
+		transaction.InstallmentNumber = existing.InstallmentNumber
+		changes, err := services.CascadeInstallmentUpdate(sessCtx, tc.db, transaction)
+		if err != nil {
+			return err
+		}
+		updatedInstallments = len(changes)
+		return services.RecordTransactionUpdates(sessCtx, tc.db, changes)
+	})
+	if errors.Is(err, services.ErrStaleVersion) {
+		var current models.Transaction
+		if err := tc.col.FindOne(ctx, bson.M{"_id": id}).Decode(&current); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		staleVersion(c, current.Version)
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Transaction updated"})
+	setETag(c, updated.Version)
+	if cascade {
+		c.JSON(http.StatusOK, gin.H{"message": "Transaction updated", "version": updated.Version, "installments_updated": updatedInstallments})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Transaction updated", "version": updated.Version})
 }
 
 // Delete removes a transaction
@@ -161,11 +511,182 @@ func (tc *TransactionController) Delete(c *gin.Context) {
 	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), tc.cfg.Timeouts.Request)
 	defer cancel()
 
-	_, err = tc.col.DeleteOne(ctx, bson.M{"_id": id})
+	filter := bson.M{"_id": id}
+
+	// cascade=true also deletes the installments after this one
+	if c.Query("cascade") == "true" {
+		var existing models.Transaction
+		if err := tc.col.FindOne(ctx, utils.NotDeleted(bson.M{"_id": id})).Decode(&existing); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+			return
+		}
+		if !existing.InstallmentGroupID.IsZero() {
+			filter = services.RemainingInstallmentsFilter(existing)
+		}
+	}
+
+	var deleted []models.Transaction
+	err = utils.RunInTransaction(ctx, tc.db, func(sessCtx mongo.SessionContext) error {
+		var err error
+		deleted, err = services.DeleteTransactions(sessCtx, tc.db, filter)
+		if err != nil {
+			return err
+		}
+		return services.RecordTransactionDeletes(sessCtx, tc.db, deleted)
+	})
 	if err != nil {
+		if services.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if len(deleted) > 1 {
+		c.JSON(http.StatusOK, gin.H{"message": "Transaction deleted", "installments_deleted": len(deleted)})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Transaction deleted"})
 }
+
+// GetDuplicates returns groups of transactions that likely record the same purchase, best score first.
+// Optional query: days (date window, default 3), min_score (0 to 1, default 0.6), account_id,
+// start_date and end_date (DATE_LAYOUT)
+func (tc *TransactionController) GetDuplicates(c *gin.Context) {
+	days := services.DefaultDuplicateWindowDays
+	if raw := c.Query("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid days"})
+			return
+		}
+		days = parsed
+	}
+	minScore := services.DefaultDuplicateMinScore
+	if raw := c.Query("min_score"); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid min_score, expected a number between 0 and 1"})
+			return
+		}
+		minScore = parsed
+	}
+
+	filter := bson.M{}
+	if raw := c.Query("account_id"); raw != "" {
+		accountID, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account_id"})
+			return
+		}
+		filter["account_id"] = accountID
+	}
+	dateFilter := bson.M{}
+	if raw := c.Query("start_date"); raw != "" {
+		start, err := utils.ParseDateToISO(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_date: " + err.Error()})
+			return
+		}
+		dateFilter["$gte"] = start
+	}
+	if raw := c.Query("end_date"); raw != "" {
+		end, err := utils.ParseDateToISO(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "end_date: " + err.Error()})
+			return
+		}
+		dateFilter["$lt"] = end.AddDate(0, 0, 1)
+	}
+	if len(dateFilter) > 0 {
+		filter["date"] = dateFilter
+	}
+
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), tc.cfg.Timeouts.Request)
+	defer cancel()
+
+	groups, err := services.FindDuplicates(ctx, tc.db, filter, days, minScore)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
+
+// mergeDuplicatesRequest is the body of MergeDuplicates
+type mergeDuplicatesRequest struct {
+	KeepID         primitive.ObjectID   `json:"keep_id" binding:"required"`
+	TransactionIDs []primitive.ObjectID `json:"transaction_ids" binding:"required,min=2"`
+}
+
+// MergeDuplicates keeps keep_id and deletes the other transactions of the group
+func (tc *TransactionController) MergeDuplicates(c *gin.Context) {
+	var request mergeDuplicatesRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), tc.cfg.Timeouts.Request)
+	defer cancel()
+
+	var kept models.Transaction
+	var deleted []models.Transaction
+	err := utils.RunInTransaction(ctx, tc.db, func(sessCtx mongo.SessionContext) error {
+		var err error
+		var before models.Transaction
+		if err := tc.col.FindOne(sessCtx, utils.NotDeleted(bson.M{"_id": request.KeepID})).Decode(&before); err != nil {
+			return err
+		}
+		kept, deleted, err = services.MergeDuplicates(sessCtx, tc.db, request.KeepID, request.TransactionIDs)
+		if err != nil {
+			return err
+		}
+		if err := services.RecordTransactionDeletes(sessCtx, tc.db, deleted); err != nil {
+			return err
+		}
+		if kept.UpdatedAt.Equal(before.UpdatedAt) {
+			return nil
+		}
+		return services.RecordAudit(sessCtx, tc.db, services.AuditTransaction, services.AuditUpdate, kept.ID, before, kept)
+	})
+	if err != nil {
+		switch {
+		case services.IsNotFound(err):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		case errors.Is(err, services.ErrNotDuplicates), errors.Is(err, services.ErrNothingToMerge):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Duplicates merged", "transaction": kept, "deleted": len(deleted)})
+}
+
+// dismissDuplicatesRequest is the body of DismissDuplicates
+type dismissDuplicatesRequest struct {
+	TransactionIDs []primitive.ObjectID `json:"transaction_ids" binding:"required,min=2"`
+}
+
+// DismissDuplicates marks a group as not duplicates, so it isn't reported again
+func (tc *TransactionController) DismissDuplicates(c *gin.Context) {
+	var request dismissDuplicatesRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), tc.cfg.Timeouts.Request)
+	defer cancel()
+
+	if err := services.DismissDuplicates(ctx, tc.db, request.TransactionIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Duplicates dismissed"})
+}
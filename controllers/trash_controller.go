@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/1v4n-ML/finance-tracker-api/config"
+	"github.com/1v4n-ML/finance-tracker-api/services"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type TrashController struct {
+	db  *mongo.Database
+	cfg *config.Config
+}
+
+func NewTrashController(db *mongo.Database, cfg *config.Config) *TrashController {
+	return &TrashController{
+		db:  db,
+		cfg: cfg,
+	}
+}
+
+// GetAll returns the deleted transactions, accounts and categories that can still be restored
+func (tc *TrashController) GetAll(c *gin.Context) {
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), tc.cfg.Timeouts.Request)
+	defer cancel()
+
+	trash, err := services.ListTrash(ctx, tc.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"retention_days": tc.cfg.Trash.RetentionDays, "trash": trash})
+}
+
+// Restore takes a document out of the trash. :kind is transactions, accounts or categories.
+// A restored transaction counts on the balances again, once its accounts are restored
+func (tc *TrashController) Restore(c *gin.Context) {
+	kind := c.Param("kind")
+	if !services.IsTrashKind(kind) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid kind, expected transactions, accounts or categories"})
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	ctx, cancel := utils.NewContextWithTimeout(c.Request.Context(), tc.cfg.Timeouts.Request)
+	defer cancel()
+
+	// The document and the balances it moves change in the same MongoDB transaction
+	err = utils.RunInTransaction(ctx, tc.db, func(sessCtx mongo.SessionContext) error {
+		return services.Restore(sessCtx, tc.db, kind, id)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found in the trash"})
+		case errors.Is(err, services.ErrRestoreDependency):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "restored"})
+}
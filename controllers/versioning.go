@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/1v4n-ML/finance-tracker-api/services"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errVersionRequired = errors.New("send the version the update is based on, in an If-Match header or a version field")
+	errVersionInvalid  = errors.New("If-Match must be the ETag of the document")
+)
+
+// expectedVersion returns the version a PUT is based on, from the If-Match header or else the version
+// field of the body. The body is read with ShouldBindBodyWithJSON so the handler can bind it again.
+// Writes the 428 or 400 response itself and returns false when there is no usable version
+func expectedVersion(c *gin.Context) (int64, bool) {
+	if header := c.GetHeader("If-Match"); header != "" {
+		tag := strings.Trim(strings.TrimPrefix(strings.TrimSpace(header), "W/"), `"`)
+		version, err := strconv.ParseInt(tag, 10, 64)
+		if err != nil || version < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errVersionInvalid.Error()})
+			return 0, false
+		}
+		return version, true
+	}
+
+	var body struct {
+		Version *int64 `json:"version"`
+	}
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, false
+	}
+	if body.Version == nil {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": errVersionRequired.Error()})
+		return 0, false
+	}
+	return *body.Version, true
+}
+
+// setETag sends the version of the document as its ETag
+func setETag(c *gin.Context, version int64) {
+	c.Header("ETag", fmt.Sprintf(`"%d"`, version))
+}
+
+// staleVersion answers 412 with the current version, so the client knows what to fetch again
+func staleVersion(c *gin.Context, current int64) {
+	setETag(c, current)
+	c.JSON(http.StatusPreconditionFailed, gin.H{"error": services.ErrStaleVersion.Error(), "version": current})
+}
@@ -4,11 +4,12 @@ import (
 	"net/http"
 
 	"github.com/1v4n-ML/finance-tracker-api/config"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
 	"github.com/gin-gonic/gin"
 )
 
 func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
-	if cfg.ApiToken == "" {
+	if cfg.ApiToken == "" && len(cfg.ApiKeys) == 0 {
 		return func(ctx *gin.Context) {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Server configuration error, auth token not set"})
 		}
@@ -19,10 +20,17 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing api key"})
 			return
 		}
-		if token != cfg.ApiToken {
+		actor, ok := cfg.ApiKeys[token]
+		if !ok && cfg.ApiToken != "" && token == cfg.ApiToken {
+			actor, ok = config.DefaultActor, true
+		}
+		if !ok {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid api key"})
 			return
 		}
+		// The identity goes along with the request context, so the audit log can name who made a change
+		ctx.Set("actor", actor)
+		ctx.Request = ctx.Request.WithContext(utils.WithActor(ctx.Request.Context(), actor))
 		ctx.Next()
 	}
 }
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the id that ties a response to its audit log entries
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDMiddleware keeps the request id sent by the client, or generates one, and echoes it in the response
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requestID := ctx.GetHeader(RequestIDHeader)
+		if len(requestID) > 128 {
+			requestID = ""
+		}
+		if requestID == "" {
+			buf := make([]byte, 16)
+			if _, err := rand.Read(buf); err == nil {
+				requestID = hex.EncodeToString(buf)
+			}
+		}
+		ctx.Set("request_id", requestID)
+		ctx.Request = ctx.Request.WithContext(utils.WithRequestID(ctx.Request.Context(), requestID))
+		ctx.Header(RequestIDHeader, requestID)
+		ctx.Next()
+	}
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in minor units (cents). It is stored in MongoDB as an int64, so balance
+// increments and aggregation sums are exact, and it is rendered in JSON as a decimal number
+// with two places ("12.34"), so clients keep sending and reading regular amounts
+type Money int64
+
+var ErrMoneyPrecision = errors.New("amounts can't have more than two decimal places")
+
+// ParseMoney parses a decimal string such as "12.34", "-0.5" or "100" without going through float64
+func ParseMoney(value string) (Money, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, errors.New("empty amount")
+	}
+
+	negative := false
+	switch value[0] {
+	case '-':
+		negative = true
+		value = value[1:]
+	case '+':
+		value = value[1:]
+	}
+
+	units, fraction, _ := strings.Cut(value, ".")
+	if units == "" && fraction == "" {
+		return 0, fmt.Errorf("invalid amount '%s'", value)
+	}
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > 2 {
+		return 0, ErrMoneyPrecision
+	}
+	for len(fraction) < 2 {
+		fraction += "0"
+	}
+	if units == "" {
+		units = "0"
+	}
+
+	whole, err := strconv.ParseInt(units, 10, 64)
+	if err != nil || whole < 0 {
+		return 0, fmt.Errorf("invalid amount '%s'", value)
+	}
+	cents, err := strconv.ParseInt(fraction, 10, 64)
+	if err != nil || cents < 0 {
+		return 0, fmt.Errorf("invalid amount '%s'", value)
+	}
+	if whole > (math.MaxInt64-cents)/100 {
+		return 0, fmt.Errorf("amount '%s' is too large", value)
+	}
+
+	total := Money(whole*100 + cents)
+	if negative {
+		total = -total
+	}
+	return total, nil
+}
+
+// MoneyFromFloat rounds a float amount to the nearest cent. Only meant for values that already
+// arrived as floats, such as report filter values
+func MoneyFromFloat(value float64) Money {
+	return Money(math.Round(value * 100))
+}
+
+// Float64 returns the amount in major units, for ratios and projections only
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+func (m Money) String() string {
+	sign := ""
+	cents := int64(m)
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts both JSON numbers and numeric strings
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	value := string(bytes.Trim(data, `"`))
+	if strings.ContainsAny(value, "eE") {
+		// Exponent notation, e.g. 1e3, is only accepted when it is a whole number of cents
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid amount %s", data)
+		}
+		if f*100 != math.Trunc(f*100) {
+			return ErrMoneyPrecision
+		}
+		*m = MoneyFromFloat(f)
+		return nil
+	}
+	parsed, err := ParseMoney(value)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
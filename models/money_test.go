@@ -0,0 +1,61 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Money
+		wantErr bool
+	}{
+		{"0.29", 29, false}, // 0.29 * 100 is 28.999999999999996 as a float
+		{"1.1", 110, false},
+		{"100", 10000, false},
+		{"12.30", 1230, false},
+		{"1.230", 123, false}, // Trailing zeros aren't extra precision
+		{".5", 50, false},
+		{"+3.10", 310, false},
+		{" 7.00 ", 700, false},
+		{"-0.29", -29, false},
+		{"-12.5", -1250, false},
+		{"-0", 0, false},
+		{"-92233720368547758.07", -9223372036854775807, false},
+		{"92233720368547758.08", 0, true},
+		{"", 0, true},
+		{"-", 0, true},
+		{".", 0, true},
+		{"--5", 0, true},
+		{"1,50", 0, true},
+		{"abc", 0, true},
+		{"1.2.3", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseMoney(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseMoney(%q) = %d, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMoney(%q) failed: %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseMoney(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMoneyPrecision(t *testing.T) {
+	for _, value := range []string{"0.001", "-1.005", "10.999"} {
+		if _, err := ParseMoney(value); !errors.Is(err, ErrMoneyPrecision) {
+			t.Errorf("ParseMoney(%q) error = %v, want ErrMoneyPrecision", value, err)
+		}
+	}
+}
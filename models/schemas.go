@@ -9,14 +9,91 @@ import (
 // Transaction represents a financial transaction
 type Transaction struct {
 	ID          primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
-	Amount      float64            `json:"amount" bson:"amount" binding:"required,min=0"`
-	Date        time.Time          `json:"date" bson:"date" binding:"required"`
+	Amount      Money              `json:"amount" bson:"amount" binding:"required_without=InstallmentPlan,min=0"`
+	Date        time.Time          `json:"date" bson:"date" binding:"required_without=InstallmentPlan"`
 	Description string             `json:"description" bson:"description"`
 	CategoryID  primitive.ObjectID `json:"category_id,omitempty" bson:"category_id,omitempty"`
-	Type        string             `json:"type" bson:"type" binding:"required,oneof=income expense"` // income or expense
-	Account     primitive.ObjectID `json:"account_id,omitempty" bson:"account_id,omitempty"`         // pix, credit card, etc.
-	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
-	UpdatedAt   time.Time          `json:"updated_at" bson:"updated_at"`
+	Type        string             `json:"type" bson:"type" binding:"required,oneof=income expense transfer"` // income, expense or transfer
+	Account     primitive.ObjectID `json:"account_id,omitempty" bson:"account_id,omitempty"`                  // pix, credit card, etc. Source account for transfers
+	// DestinationAccount is only set for transfers and receives the amount taken from Account
+	DestinationAccount primitive.ObjectID `json:"destination_account_id,omitempty" bson:"destination_account_id,omitempty"`
+	// Currency is the ISO 4217 code of Amount and always matches the currency of Account
+	Currency string `json:"currency,omitempty" bson:"currency,omitempty" binding:"omitempty,iso4217"`
+	// DestinationAmount is what the destination account receives when a transfer crosses currencies
+	DestinationAmount Money `json:"destination_amount,omitempty" bson:"destination_amount,omitempty" binding:"omitempty,gt=0"`
+	// StatementPeriod is the credit card invoice month (YYYY-MM) a purchase belongs to, or the one a payment transfer pays
+	StatementPeriod string `json:"statement_period,omitempty" bson:"statement_period,omitempty"`
+	// Installments generated from the same purchase share a group and are numbered k/N
+	InstallmentGroupID primitive.ObjectID `json:"installment_group_id,omitempty" bson:"installment_group_id,omitempty"`
+	InstallmentNumber  int                `json:"installment_number,omitempty" bson:"installment_number,omitempty"`
+	InstallmentCount   int                `json:"installment_count,omitempty" bson:"installment_count,omitempty"`
+	// InstallmentPlan is only read on creation and expanded into the child installments
+	InstallmentPlan *InstallmentPlan `json:"installment_plan,omitempty" bson:"-"`
+	// Transactions materialized from a Recurrence keep a link to it and the occurrence they stand for
+	RecurrenceID   primitive.ObjectID `json:"recurrence_id,omitempty" bson:"recurrence_id,omitempty"`
+	OccurrenceDate *time.Time         `json:"occurrence_date,omitempty" bson:"occurrence_date,omitempty"`
+	// GoalID tags the transaction as a contribution to (or withdrawal from) a savings goal
+	GoalID primitive.ObjectID `json:"goal_id,omitempty" bson:"goal_id,omitempty"`
+	// Splits spread the amount over several categories; they must add up to Amount
+	Splits []Split `json:"splits,omitempty" bson:"splits,omitempty" binding:"omitempty,dive"`
+	// Tags mark transactions across categories, e.g. "vacation-2026" or "reimbursable"
+	Tags []string `json:"tags,omitempty" bson:"tags,omitempty"`
+	// ExternalID is the bank's id for an imported transaction (OFX FITID), unique within the account
+	ExternalID string    `json:"external_id,omitempty" bson:"external_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" bson:"updated_at"`
+	// DeletedAt puts the transaction in the trash: it no longer counts anywhere until restored or purged
+	DeletedAt *time.Time `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+	// Version grows on every write and is sent as the ETag; a PUT based on an older version is refused
+	Version int64 `json:"version" bson:"version,omitempty"`
+}
+
+// Split is the part of a transaction that belongs to one category, e.g. the cleaning products of a supermarket receipt
+type Split struct {
+	CategoryID primitive.ObjectID `json:"category_id" bson:"category_id" binding:"required"`
+	Amount     Money              `json:"amount" bson:"amount" binding:"required,gt=0"`
+	Note       string             `json:"note,omitempty" bson:"note,omitempty"`
+}
+
+// InstallmentPlan splits a purchase into Count monthly installments, the first one charged on FirstDueDate
+type InstallmentPlan struct {
+	TotalAmount  Money     `json:"total_amount" binding:"required,gt=0"`
+	Count        int       `json:"count" binding:"required,min=2,max=120"`
+	FirstDueDate time.Time `json:"first_due_date" binding:"required"`
+}
+
+// Recurrence is a template transaction that is materialized on a schedule (rent, salary, subscriptions)
+type Recurrence struct {
+	ID       primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Template RecurrenceTemplate `json:"template" bson:"template" binding:"required"`
+	Schedule Schedule           `json:"schedule" bson:"schedule" binding:"required"`
+	Paused   bool               `json:"paused" bson:"paused"`
+	// LastOccurrence is the date of the last occurrence turned into a transaction
+	LastOccurrence *time.Time `json:"last_occurrence,omitempty" bson:"last_occurrence,omitempty"`
+	CreatedAt      time.Time  `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" bson:"updated_at"`
+}
+
+// RecurrenceTemplate holds the transaction fields copied into every occurrence
+type RecurrenceTemplate struct {
+	Amount             Money              `json:"amount" bson:"amount" binding:"required,min=0"`
+	Description        string             `json:"description" bson:"description"`
+	CategoryID         primitive.ObjectID `json:"category_id,omitempty" bson:"category_id,omitempty"`
+	Type               string             `json:"type" bson:"type" binding:"required,oneof=income expense transfer"`
+	Account            primitive.ObjectID `json:"account_id,omitempty" bson:"account_id,omitempty"`
+	DestinationAccount primitive.ObjectID `json:"destination_account_id,omitempty" bson:"destination_account_id,omitempty"`
+	DestinationAmount  Money              `json:"destination_amount,omitempty" bson:"destination_amount,omitempty" binding:"omitempty,gt=0"`
+}
+
+// Schedule is a small RRULE-like rule: every Interval days/weeks/months/years from StartDate,
+// until EndDate or Count occurrences, whichever comes first
+type Schedule struct {
+	Frequency  string     `json:"frequency" bson:"frequency" binding:"required,oneof=daily weekly monthly yearly"`
+	Interval   int        `json:"interval,omitempty" bson:"interval,omitempty" binding:"omitempty,min=1"`                // Defaults to 1
+	DayOfMonth int        `json:"day_of_month,omitempty" bson:"day_of_month,omitempty" binding:"omitempty,min=1,max=31"` // Monthly only, defaults to the StartDate day
+	StartDate  time.Time  `json:"start_date" bson:"start_date" binding:"required"`
+	EndDate    *time.Time `json:"end_date,omitempty" bson:"end_date,omitempty"`
+	Count      int        `json:"count,omitempty" bson:"count,omitempty" binding:"omitempty,min=1"`
 }
 
 // Category represents a transaction category
@@ -26,20 +103,212 @@ type Category struct {
 	Description string             `json:"description,omitempty" bson:"description,omitempty"`
 	Color       string             `json:"color,omitempty" bson:"color,omitempty"`                   // For UI representation
 	Type        string             `json:"type" bson:"type" binding:"required,oneof=income expense"` // income or expense
+	ParentID    primitive.ObjectID `json:"parent_id,omitempty" bson:"parent_id,omitempty"`           // Subcategories share the type of their parent
 	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt   time.Time          `json:"updated_at" bson:"updated_at"`
+	DeletedAt   *time.Time         `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"` // Set while the category is in the trash
+	Version     int64              `json:"version" bson:"version,omitempty"`                 // Grows on every write, sent as the ETag
+}
+
+// CategoryNode is a category with its subcategories, as returned by the tree endpoint
+type CategoryNode struct {
+	Category
+	Children []CategoryNode `json:"children"`
 }
 
 type Account struct {
 	ID         primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
 	Name       string             `json:"name" bson:"name" binding:"required"`
 	Type       string             `json:"type" bson:"type" binding:"required,oneof=wallet bank credit_card"`
-	Balance    float64            `json:"balance" bson:"balance"`
+	Balance    Money              `json:"balance" bson:"balance"` // Always in the account currency
+	Currency   string             `json:"currency" bson:"currency" binding:"omitempty,iso4217"`
 	Color      string             `json:"color,omitempty" bson:"color,omitempty"` // For UI representation
 	ClosureDay int                `json:"closure_day" bson:"closure_day" binding:"omitempty,required_if=Type credit_card,gte=1,lte=31"`
 	PayDay     int                `json:"payday" bson:"payday" binding:"omitempty,required_with=ClosureDay,gte=1,lte=31"`
 	CreatedAt  time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt  time.Time          `json:"updated_at" bson:"updated_at"`
+	DeletedAt  *time.Time         `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"` // Set while the account is in the trash
+	Version    int64              `json:"version" bson:"version,omitempty"`                 // Grows on every write except balance moves, sent as the ETag
+}
+
+// BalanceDifference is an account whose stored balance doesn't match the sum of its transactions
+type BalanceDifference struct {
+	AccountID  primitive.ObjectID `json:"account_id"`
+	Name       string             `json:"name"`
+	Stored     Money              `json:"stored"`
+	Expected   Money              `json:"expected"`
+	Difference Money              `json:"difference"` // Expected - Stored
+	Fixed      bool               `json:"fixed"`
+}
+
+// Statement is a credit card billing cycle built from the account ClosureDay and PayDay
+type Statement struct {
+	AccountID    primitive.ObjectID `json:"account_id"`
+	Period       string             `json:"period"` // YYYY-MM of the closing date
+	OpeningDate  time.Time          `json:"opening_date"`
+	ClosingDate  time.Time          `json:"closing_date"`
+	DueDate      time.Time          `json:"due_date"`
+	Status       string             `json:"status"` // open, closed or paid
+	Charges      Money              `json:"charges"`
+	Credits      Money              `json:"credits"`
+	Total        Money              `json:"total"`
+	Paid         Money              `json:"paid"`
+	Outstanding  Money              `json:"outstanding"`
+	PaidAt       *time.Time         `json:"paid_at,omitempty"`
+	Transactions []Transaction      `json:"transactions,omitempty"`
+}
+
+// Budget is a spending limit for one or more expense categories over a month or a custom date range
+type Budget struct {
+	ID          primitive.ObjectID   `json:"id,omitempty" bson:"_id,omitempty"`
+	Name        string               `json:"name" bson:"name" binding:"required"`
+	CategoryIDs []primitive.ObjectID `json:"category_ids" bson:"category_ids" binding:"required,min=1"`
+	Amount      Money                `json:"amount" bson:"amount" binding:"required,gt=0"`
+	Period      string               `json:"period" bson:"period" binding:"required,oneof=monthly custom"`
+	StartDate   time.Time            `json:"start_date" bson:"start_date" binding:"required"` // First month of monthly budgets
+	EndDate     *time.Time           `json:"end_date,omitempty" bson:"end_date,omitempty" binding:"required_if=Period custom"`
+	Rollover    bool                 `json:"rollover" bson:"rollover"` // Carry unspent money into the next month
+	CreatedAt   time.Time            `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at" bson:"updated_at"`
+}
+
+// BudgetProgress is the state of a budget in the period containing a reference date
+type BudgetProgress struct {
+	BudgetID    primitive.ObjectID `json:"budget_id"`
+	PeriodStart time.Time          `json:"period_start"`
+	PeriodEnd   time.Time          `json:"period_end"` // Exclusive
+	Amount      Money              `json:"amount"`
+	RolledOver  Money              `json:"rolled_over"`
+	Available   Money              `json:"available"`
+	Spent       Money              `json:"spent"`
+	Remaining   Money              `json:"remaining"`
+	PercentUsed float64            `json:"percent_used"`
+}
+
+// Goal is a savings target. Progress comes from the linked account balance when AccountID is set,
+// otherwise from the transactions tagged with the goal ID
+type Goal struct {
+	ID           primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Name         string             `json:"name" bson:"name" binding:"required"`
+	Description  string             `json:"description,omitempty" bson:"description,omitempty"`
+	TargetAmount Money              `json:"target_amount" bson:"target_amount" binding:"required,gt=0"`
+	TargetDate   time.Time          `json:"target_date" bson:"target_date" binding:"required"`
+	AccountID    primitive.ObjectID `json:"account_id,omitempty" bson:"account_id,omitempty"`
+	// InitialAmount is the linked account balance when the goal was created, used to measure the saving pace
+	InitialAmount Money     `json:"initial_amount" bson:"initial_amount"`
+	CreatedAt     time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// GoalProgress reports how far a goal is and whether the current pace reaches it in time
+type GoalProgress struct {
+	GoalID              primitive.ObjectID `json:"goal_id"`
+	CurrentAmount       Money              `json:"current_amount"`
+	TargetAmount        Money              `json:"target_amount"`
+	Remaining           Money              `json:"remaining"`
+	PercentComplete     float64            `json:"percent_complete"`
+	MonthsLeft          float64            `json:"months_left"`
+	MonthlyNeeded       Money              `json:"monthly_needed"`       // Contribution per month needed to reach the target on time
+	MonthlyPace         Money              `json:"monthly_pace"`         // Average contribution per month so far
+	ProjectedCompletion *time.Time         `json:"projected_completion"` // Null when the pace is not positive or projects past 100 years
+	Status              string             `json:"status"`               // completed, on_track or behind
+}
+
+// ExchangeRate is the rate of a currency pair on a date: 1 unit of the first currency of Pair ("USD/BRL")
+// is worth Rate units of the second one
+type ExchangeRate struct {
+	ID        primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Date      time.Time          `json:"date" bson:"date" binding:"required"`
+	Pair      string             `json:"pair" bson:"pair" binding:"required"`
+	Rate      float64            `json:"rate" bson:"rate" binding:"required,gt=0"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// ImportProfile describes how to read the CSV export of a bank.
+// Columns are referenced by header name (HasHeader) or by 1-based position
+type ImportProfile struct {
+	ID               primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Name             string             `json:"name" bson:"name" binding:"required"`
+	Delimiter        string             `json:"delimiter" bson:"delimiter" binding:"omitempty,len=1"` // Defaults to ","
+	SkipRows         int                `json:"skip_rows" bson:"skip_rows" binding:"min=0"`           // Lines before the header or the first row
+	HasHeader        bool               `json:"has_header" bson:"has_header"`
+	Columns          ImportColumns      `json:"columns" bson:"columns"`
+	DateLayout       string             `json:"date_layout" bson:"date_layout"`                                // Go layout, e.g. 02/01/2006. Defaults to DATE_LAYOUT
+	DecimalSeparator string             `json:"decimal_separator" bson:"decimal_separator" binding:"required"` // "." or ","
+	// SignConvention tells income from expense:
+	// negative_expense (bank accounts), negative_income (card exports list purchases as positive),
+	// debit_credit (separate Debit and Credit columns) or type_column (IncomeValues/ExpenseValues in the Type column)
+	SignConvention  string             `json:"sign_convention" bson:"sign_convention" binding:"required,oneof=negative_expense negative_income debit_credit type_column"`
+	IncomeValues    []string           `json:"income_values,omitempty" bson:"income_values,omitempty"`
+	ExpenseValues   []string           `json:"expense_values,omitempty" bson:"expense_values,omitempty"`
+	DefaultAccount  primitive.ObjectID `json:"account_id,omitempty" bson:"account_id,omitempty"`
+	DefaultCategory primitive.ObjectID `json:"category_id,omitempty" bson:"category_id,omitempty"`
+	CreatedAt       time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// ImportColumns maps transaction fields to CSV columns
+type ImportColumns struct {
+	Date        string `json:"date" bson:"date" binding:"required"`
+	Description string `json:"description,omitempty" bson:"description,omitempty"`
+	Amount      string `json:"amount,omitempty" bson:"amount,omitempty"`
+	Debit       string `json:"debit,omitempty" bson:"debit,omitempty"`
+	Credit      string `json:"credit,omitempty" bson:"credit,omitempty"`
+	Type        string `json:"type,omitempty" bson:"type,omitempty"`
+	ExternalID  string `json:"external_id,omitempty" bson:"external_id,omitempty"` // Bank id of the row, used to skip re-imports
+}
+
+// DuplicateDismissal records transactions the user confirmed aren't duplicates of each other
+type DuplicateDismissal struct {
+	ID             primitive.ObjectID   `json:"id,omitempty" bson:"_id,omitempty"`
+	TransactionIDs []primitive.ObjectID `json:"transaction_ids" bson:"transaction_ids"`
+	CreatedAt      time.Time            `json:"created_at" bson:"created_at"`
+}
+
+// AuditEntry records one change to a transaction, account or category: who made it, in which request,
+// and the document before and after. Creates have no Before and deletes no After
+type AuditEntry struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	Entity    string             `json:"entity" bson:"entity"` // transaction, account or category
+	EntityID  primitive.ObjectID `json:"entity_id" bson:"entity_id"`
+	Action    string             `json:"action" bson:"action"` // create, update, delete or restore
+	Actor     string             `json:"actor" bson:"actor"`   // Identity of the API key, "system" for scheduled work
+	RequestID string             `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	Timestamp time.Time          `json:"timestamp" bson:"timestamp"`
+	Before    interface{}        `json:"before,omitempty" bson:"before,omitempty"`
+	After     interface{}        `json:"after,omitempty" bson:"after,omitempty"`
+}
+
+// Rule fills in the category, tags or description of the transactions it matches.
+// Rules run by ascending Priority; every condition set must match
+type Rule struct {
+	ID         primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Name       string             `json:"name" bson:"name" binding:"required"`
+	Priority   int                `json:"priority" bson:"priority"`
+	Disabled   bool               `json:"disabled" bson:"disabled"`
+	Stop       bool               `json:"stop" bson:"stop"` // Don't run the rules after this one when it matches
+	Conditions RuleConditions     `json:"conditions" bson:"conditions"`
+	Actions    RuleActions        `json:"actions" bson:"actions"`
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt  time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// RuleConditions are matched against a transaction. Description matching is case-insensitive
+type RuleConditions struct {
+	DescriptionContains string             `json:"description_contains,omitempty" bson:"description_contains,omitempty"`
+	DescriptionRegex    string             `json:"description_regex,omitempty" bson:"description_regex,omitempty"`
+	MinAmount           *Money             `json:"min_amount,omitempty" bson:"min_amount,omitempty"`
+	MaxAmount           *Money             `json:"max_amount,omitempty" bson:"max_amount,omitempty"`
+	AccountID           primitive.ObjectID `json:"account_id,omitempty" bson:"account_id,omitempty"`
+	Type                string             `json:"type,omitempty" bson:"type,omitempty" binding:"omitempty,oneof=income expense transfer"`
+}
+
+// RuleActions are applied to the matching transactions
+type RuleActions struct {
+	CategoryID  primitive.ObjectID `json:"category_id,omitempty" bson:"category_id,omitempty"`
+	Tags        []string           `json:"tags,omitempty" bson:"tags,omitempty"`
+	Description string             `json:"description,omitempty" bson:"description,omitempty"` // Replaces the bank's description
 }
 
 type Filter struct {
@@ -55,10 +324,22 @@ type Metric struct {
 }
 
 type AggregationRequest struct {
-	Filters []Filter       `json:"filters"`
-	GroupBy []string       `json:"groupBy" binding:"required,min=1"`
-	Metrics []Metric       `json:"metrics" binding:"required,min=1"`
-	SortBy  map[string]int `json:"sortBy"` // Key: field name, Value: 1 (asc) or -1 (desc)
-	Limit   *int64         `json:"limit"`  // Use pointer for optional field
-	Offset  *int64         `json:"offset"` // Use pointer for optional field
+	Filters          []Filter       `json:"filters"`
+	GroupBy          []string       `json:"groupBy" binding:"required,min=1"`
+	Metrics          []Metric       `json:"metrics" binding:"required,min=1"`
+	SortBy           map[string]int `json:"sortBy"`           // Key: field name, Value: 1 (asc) or -1 (desc)
+	Limit            *int64         `json:"limit"`            // Use pointer for optional field
+	Offset           *int64         `json:"offset"`           // Use pointer for optional field
+	IncludeTransfers bool           `json:"includeTransfers"` // Transfers are left out of the totals unless requested
+	ConvertTo        string         `json:"convertTo"`        // Optional ISO 4217 code every amount is converted into, using the rate on the transaction date
+	// CategoryLevel rolls category_id up to the ancestor at that depth of the category tree (0 = top level)
+	// before filtering and grouping, so a top-level category includes all its descendants
+	CategoryLevel *int `json:"categoryLevel" binding:"omitempty,min=0"`
+}
+
+// Page is one page of a list endpoint. NextCursor is sent back as ?cursor= for the following page,
+// and is null on the last one
+type Page struct {
+	Items      interface{} `json:"items"`
+	NextCursor *string     `json:"next_cursor"`
 }
@@ -16,17 +16,29 @@ func SetupRouter(db *mongo.Database, cfg *config.Config) *gin.Engine {
 
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"}, //this should be more restricted for prod
-		AllowMethods:     []string{"GET", "POST", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "If-Match", middleware.RequestIDHeader},
+		ExposeHeaders:    []string{"Content-Length", "ETag", middleware.RequestIDHeader},
 		AllowCredentials: true,
 	}))
+	router.Use(middleware.RequestIDMiddleware())
 
 	// Create controllers with database dependency
 	transactionController := controllers.NewTransactionController(db, cfg)
 	categoryController := controllers.NewCategoryController(db, cfg)
 	accountsController := controllers.NewAccountController(db, cfg)
 	reportsController := controllers.NewReportsController(db, cfg)
+	statementController := controllers.NewStatementController(db, cfg)
+	recurrenceController := controllers.NewRecurrenceController(db, cfg)
+	budgetController := controllers.NewBudgetController(db, cfg)
+	goalController := controllers.NewGoalController(db, cfg)
+	exchangeRateController := controllers.NewExchangeRateController(db, cfg)
+	importController := controllers.NewImportController(db, cfg)
+	backupController := controllers.NewBackupController(db, cfg)
+	ruleController := controllers.NewRuleController(db, cfg)
+	tagController := controllers.NewTagController(db, cfg)
+	trashController := controllers.NewTrashController(db, cfg)
+	auditController := controllers.NewAuditController(db, cfg)
 
 	// API routes - no authentication needed
 	api := router.Group("/api")
@@ -36,9 +48,15 @@ func SetupRouter(db *mongo.Database, cfg *config.Config) *gin.Engine {
 		transactions := api.Group("/transactions")
 		{
 			transactions.GET("", transactionController.GetAll)
+			transactions.GET("/search", transactionController.Search)
+			transactions.GET("/duplicates", transactionController.GetDuplicates)
+			transactions.POST("/duplicates/merge", transactionController.MergeDuplicates)
+			transactions.POST("/duplicates/dismiss", transactionController.DismissDuplicates)
 			transactions.GET("/:id", transactionController.GetByID)
 			transactions.POST("", transactionController.Create)
+			transactions.POST("/suggest-category", transactionController.SuggestCategory)
 			transactions.PUT("/:id", transactionController.Update)
+			transactions.PATCH("/:id", transactionController.Patch)
 			transactions.DELETE("/:id", transactionController.Delete)
 		}
 
@@ -46,8 +64,11 @@ func SetupRouter(db *mongo.Database, cfg *config.Config) *gin.Engine {
 		categories := api.Group("/categories")
 		{
 			categories.GET("", categoryController.GetAllCategories)
+			categories.GET("/tree", categoryController.GetCategoryTree)
+			categories.GET("/:id", categoryController.GetCategory)
 			categories.POST("", categoryController.CreateCategory)
 			categories.PUT("/:id", categoryController.UpdateCategory)
+			categories.PATCH("/:id", categoryController.PatchCategory)
 			categories.DELETE("/:id", categoryController.DeleteCategory)
 		}
 
@@ -58,10 +79,107 @@ func SetupRouter(db *mongo.Database, cfg *config.Config) *gin.Engine {
 			accounts.GET("/:id", accountsController.GetAccountById)
 			accounts.POST("", accountsController.CreateAccount)
 			accounts.PUT("/:id", accountsController.UpdateAccount)
+			accounts.PATCH("/:id", accountsController.PatchAccount)
 			accounts.DELETE("/:id", accountsController.DeleteAccount)
 			accounts.POST("/recalculate-balances", accountsController.RecalculateAllBalances)
+
+			// Credit card statements
+			accounts.GET("/:id/statements", statementController.GetStatements)
+			accounts.GET("/:id/statements/:period", statementController.GetStatementByPeriod)
+		}
+
+		// Recurring transaction routes
+		recurrences := api.Group("/recurrences")
+		{
+			recurrences.GET("", recurrenceController.GetAll)
+			recurrences.GET("/upcoming", recurrenceController.Upcoming)
+			recurrences.POST("/run", recurrenceController.Run)
+			recurrences.GET("/:id", recurrenceController.GetByID)
+			recurrences.GET("/:id/preview", recurrenceController.Preview)
+			recurrences.POST("", recurrenceController.Create)
+			recurrences.PUT("/:id", recurrenceController.Update)
+			recurrences.DELETE("/:id", recurrenceController.Delete)
 		}
 
+		// Budget routes
+		budgets := api.Group("/budgets")
+		{
+			budgets.GET("", budgetController.GetAll)
+			budgets.GET("/:id", budgetController.GetByID)
+			budgets.GET("/:id/progress", budgetController.Progress)
+			budgets.POST("", budgetController.Create)
+			budgets.PUT("/:id", budgetController.Update)
+			budgets.DELETE("/:id", budgetController.Delete)
+		}
+
+		// Savings goal routes
+		goals := api.Group("/goals")
+		{
+			goals.GET("", goalController.GetAll)
+			goals.GET("/:id", goalController.GetByID)
+			goals.GET("/:id/progress", goalController.Progress)
+			goals.POST("", goalController.Create)
+			goals.PUT("/:id", goalController.Update)
+			goals.DELETE("/:id", goalController.Delete)
+		}
+
+		// Exchange rate routes
+		exchangeRates := api.Group("/exchange-rates")
+		{
+			exchangeRates.GET("", exchangeRateController.GetAll)
+			exchangeRates.POST("", exchangeRateController.Create)
+			exchangeRates.POST("/import", exchangeRateController.Import)
+			exchangeRates.DELETE("/:id", exchangeRateController.Delete)
+		}
+
+		// Tag routes
+		tags := api.Group("/tags")
+		{
+			tags.GET("", tagController.GetAll)
+			tags.POST("/merge", tagController.Merge)
+			tags.PUT("/*tag", tagController.Rename) // Catch-all, tags may hold a "/"
+		}
+
+		// Trash routes
+		trash := api.Group("/trash")
+		{
+			trash.GET("", trashController.GetAll)
+			trash.POST("/:kind/:id/restore", trashController.Restore)
+		}
+
+		// Audit log routes, read-only
+		audit := api.Group("/audit")
+		{
+			audit.GET("", auditController.GetAll)
+		}
+
+		// Categorization rule routes
+		rules := api.Group("/rules")
+		{
+			rules.GET("", ruleController.GetAll)
+			rules.POST("/run", ruleController.Run)
+			rules.GET("/:id", ruleController.GetByID)
+			rules.POST("", ruleController.Create)
+			rules.PUT("/:id", ruleController.Update)
+			rules.DELETE("/:id", ruleController.Delete)
+		}
+
+		// Import routes
+		imports := api.Group("/import")
+		{
+			imports.POST("/ofx", importController.OFX)
+			imports.POST("/csv", importController.CSV)
+			imports.GET("/profiles", importController.GetProfiles)
+			imports.GET("/profiles/:id", importController.GetProfile)
+			imports.POST("/profiles", importController.CreateProfile)
+			imports.PUT("/profiles/:id", importController.UpdateProfile)
+			imports.DELETE("/profiles/:id", importController.DeleteProfile)
+			imports.POST("/backup", backupController.Restore)
+		}
+
+		// Backup export route
+		api.GET("/export", backupController.Export)
+
 		// Report route
 		reports := api.Group("/report")
 		{
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Audited entities and actions
+const (
+	AuditTransaction = "transaction"
+	AuditAccount     = "account"
+	AuditCategory    = "category"
+
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+)
+
+// RecordAudit appends an entry to the audit log. The actor and request id come from ctx, as set by the
+// auth and request id middlewares. Called inside utils.RunInTransaction, the entry commits with the change.
+// before and after are the documents, nil when the action has none
+func RecordAudit(ctx context.Context, db *mongo.Database, entity, action string, entityID primitive.ObjectID, before, after interface{}) error {
+	_, err := db.Collection("audit_log").InsertOne(ctx, models.AuditEntry{
+		ID:        primitive.NewObjectID(),
+		Entity:    entity,
+		EntityID:  entityID,
+		Action:    action,
+		Actor:     utils.ActorFrom(ctx),
+		RequestID: utils.RequestIDFrom(ctx),
+		Timestamp: time.Now(),
+		Before:    before,
+		After:     after,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// RecordTransactionUpdates records an update entry per changed transaction
+func RecordTransactionUpdates(ctx context.Context, db *mongo.Database, changes []TransactionChange) error {
+	for _, change := range changes {
+		if err := RecordAudit(ctx, db, AuditTransaction, AuditUpdate, change.Before.ID, change.Before, change.After); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordTransactionDeletes records a delete entry per transaction moved to the trash
+func RecordTransactionDeletes(ctx context.Context, db *mongo.Database, deleted []models.Transaction) error {
+	for _, transaction := range deleted {
+		if err := RecordAudit(ctx, db, AuditTransaction, AuditDelete, transaction.ID, transaction, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// auditDocument decodes a stored before/after document into its model, so money and ids
+// are rendered as everywhere else in the API
+func auditDocument(entity string, raw bson.Raw) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var document interface{}
+	switch entity {
+	case AuditTransaction:
+		document = &models.Transaction{}
+	case AuditAccount:
+		document = &models.Account{}
+	case AuditCategory:
+		document = &models.Category{}
+	default:
+		document = &bson.M{}
+	}
+	if err := bson.Unmarshal(raw, document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// ListAudit returns the audit entries matching filter, newest first
+func ListAudit(ctx context.Context, db *mongo.Database, filter bson.M, limit int64) ([]models.AuditEntry, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(limit)
+	cursor, err := db.Collection("audit_log").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var stored []struct {
+		ID        primitive.ObjectID `bson:"_id"`
+		Entity    string             `bson:"entity"`
+		EntityID  primitive.ObjectID `bson:"entity_id"`
+		Action    string             `bson:"action"`
+		Actor     string             `bson:"actor"`
+		RequestID string             `bson:"request_id"`
+		Timestamp time.Time          `bson:"timestamp"`
+		Before    bson.Raw           `bson:"before"`
+		After     bson.Raw           `bson:"after"`
+	}
+	if err := cursor.All(ctx, &stored); err != nil {
+		return nil, err
+	}
+
+	entries := make([]models.AuditEntry, 0, len(stored))
+	for _, row := range stored {
+		entry := models.AuditEntry{
+			ID:        row.ID,
+			Entity:    row.Entity,
+			EntityID:  row.EntityID,
+			Action:    row.Action,
+			Actor:     row.Actor,
+			RequestID: row.RequestID,
+			Timestamp: row.Timestamp,
+		}
+		if entry.Before, err = auditDocument(row.Entity, row.Before); err != nil {
+			return nil, err
+		}
+		if entry.After, err = auditDocument(row.Entity, row.After); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,348 @@
+package services
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	backupFormat       = "finance-tracker-backup"
+	backupManifestName = "manifest.json"
+	// maxBackupLine fits the largest BSON document MongoDB accepts, written as Extended JSON
+	maxBackupLine = 64 * 1024 * 1024
+)
+
+// ErrInvalidBackup is returned when the archive or its manifest can't be restored
+var ErrInvalidBackup = errors.New("invalid backup archive")
+
+// BackupManifest describes a backup archive. SchemaVersion tells which migrations its documents went through
+type BackupManifest struct {
+	Format        string           `json:"format"`
+	SchemaVersion int              `json:"schema_version"`
+	ExportedAt    time.Time        `json:"exported_at"`
+	Collections   map[string]int64 `json:"collections"`
+}
+
+// RestoreReport tells how a backup was restored and how many documents of each collection were inserted.
+// After a failure RolledBack tells whether the inserted documents were removed again; when it is false
+// the collections count what was left in the database
+type RestoreReport struct {
+	Mode          string                             `json:"mode"` // restore (empty database) or merge
+	SchemaVersion int                                `json:"schema_version"`
+	Collections   map[string]RestoreCollectionReport `json:"collections"`
+	RolledBack    bool                               `json:"rolled_back,omitempty"`
+}
+
+// RestoreCollectionReport counts the documents of a collection. Skipped ones clashed with a unique index
+type RestoreCollectionReport struct {
+	Inserted int `json:"inserted"`
+	Skipped  int `json:"skipped"`
+}
+
+// backupCollections lists the collections holding user data; the migrations bookkeeping stays out
+func backupCollections(ctx context.Context, db *mongo.Database) ([]string, error) {
+	names, err := db.ListCollectionNames(ctx, bson.M{"type": "collection"})
+	if err != nil {
+		return nil, err
+	}
+	collections := make([]string, 0, len(names))
+	for _, name := range names {
+		if name == "migrations" || strings.HasPrefix(name, "system.") {
+			continue
+		}
+		collections = append(collections, name)
+	}
+	return collections, nil
+}
+
+// WriteBackup writes a zip archive with one JSON Lines file per collection and a manifest.
+// Documents are canonical Extended JSON, which keeps ObjectIDs, dates and int64 money exact
+func WriteBackup(ctx context.Context, db *mongo.Database, w io.Writer) error {
+	collections, err := backupCollections(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+	manifest := BackupManifest{
+		Format:        backupFormat,
+		SchemaVersion: SchemaVersion(),
+		ExportedAt:    time.Now().UTC(),
+		Collections:   map[string]int64{},
+	}
+
+	for _, name := range collections {
+		file, err := archive.Create(name + ".jsonl")
+		if err != nil {
+			return err
+		}
+		cursor, err := db.Collection(name).Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+		if err != nil {
+			return err
+		}
+		count := int64(0)
+		for cursor.Next(ctx) {
+			line, err := bson.MarshalExtJSON(cursor.Current, true, false)
+			if err != nil {
+				cursor.Close(ctx)
+				return err
+			}
+			if _, err := file.Write(append(line, '\n')); err != nil {
+				cursor.Close(ctx)
+				return err
+			}
+			count++
+		}
+		err = cursor.Err()
+		cursor.Close(ctx)
+		if err != nil {
+			return err
+		}
+		manifest.Collections[name] = count
+	}
+
+	file, err := archive.Create(backupManifestName)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(file).Encode(manifest); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// readBackup reads the manifest and the documents of every collection in the archive
+func readBackup(r io.ReaderAt, size int64) (BackupManifest, map[string][]bson.D, error) {
+	var manifest BackupManifest
+	documents := map[string][]bson.D{}
+
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return manifest, nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+
+	files := map[string]*zip.File{}
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+	manifestFile, ok := files[backupManifestName]
+	if !ok {
+		return manifest, nil, fmt.Errorf("%w: manifest.json not found", ErrInvalidBackup)
+	}
+	if err := readZipFile(manifestFile, func(content io.Reader) error {
+		return json.NewDecoder(content).Decode(&manifest)
+	}); err != nil {
+		return manifest, nil, fmt.Errorf("%w: invalid manifest: %v", ErrInvalidBackup, err)
+	}
+	if manifest.Format != backupFormat {
+		return manifest, nil, fmt.Errorf("%w: unknown backup format '%s'", ErrInvalidBackup, manifest.Format)
+	}
+	if manifest.SchemaVersion < 0 || manifest.SchemaVersion > SchemaVersion() {
+		return manifest, nil, fmt.Errorf("%w: backup schema version %d is newer than this server's (%d)", ErrInvalidBackup, manifest.SchemaVersion, SchemaVersion())
+	}
+
+	for name := range manifest.Collections {
+		file, ok := files[name+".jsonl"]
+		if !ok {
+			return manifest, nil, fmt.Errorf("%w: %s.jsonl not found", ErrInvalidBackup, name)
+		}
+		err := readZipFile(file, func(content io.Reader) error {
+			scanner := bufio.NewScanner(content)
+			scanner.Buffer(make([]byte, 64*1024), maxBackupLine)
+			line := 0
+			for scanner.Scan() {
+				line++
+				if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+					continue
+				}
+				var document bson.D
+				if err := bson.UnmarshalExtJSON(scanner.Bytes(), true, &document); err != nil {
+					return fmt.Errorf("line %d: %w", line, err)
+				}
+				documents[name] = append(documents[name], document)
+			}
+			return scanner.Err()
+		})
+		if err != nil {
+			return manifest, nil, fmt.Errorf("%w: invalid %s.jsonl: %v", ErrInvalidBackup, name, err)
+		}
+	}
+	return manifest, documents, nil
+}
+
+func readZipFile(file *zip.File, read func(io.Reader) error) error {
+	content, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+	return read(content)
+}
+
+// isEmptyDatabase reports whether no collection holds user data
+func isEmptyDatabase(ctx context.Context, db *mongo.Database) (bool, error) {
+	collections, err := backupCollections(ctx, db)
+	if err != nil {
+		return false, err
+	}
+	for _, name := range collections {
+		count, err := db.Collection(name).CountDocuments(ctx, bson.M{}, options.Count().SetLimit(1))
+		if err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// collectObjectIDs gives every ObjectID found at any depth of value a new id in remap
+func collectObjectIDs(value interface{}, remap map[primitive.ObjectID]primitive.ObjectID) {
+	switch v := value.(type) {
+	case primitive.ObjectID:
+		if _, ok := remap[v]; !ok && !v.IsZero() {
+			remap[v] = primitive.NewObjectID()
+		}
+	case bson.D:
+		for _, element := range v {
+			collectObjectIDs(element.Value, remap)
+		}
+	case bson.A:
+		for _, element := range v {
+			collectObjectIDs(element, remap)
+		}
+	}
+}
+
+// remapObjectIDs replaces every ObjectID found in remap, at any depth of the document
+func remapObjectIDs(value interface{}, remap map[primitive.ObjectID]primitive.ObjectID) interface{} {
+	switch v := value.(type) {
+	case primitive.ObjectID:
+		if id, ok := remap[v]; ok {
+			return id
+		}
+		return v
+	case bson.D:
+		for i := range v {
+			v[i].Value = remapObjectIDs(v[i].Value, remap)
+		}
+		return v
+	case bson.A:
+		for i := range v {
+			v[i] = remapObjectIDs(v[i], remap)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// RestoreBackup loads a backup archive. Into an empty database the documents keep their ids.
+// Into a non-empty one they are merged: every ObjectID in the backup is replaced by a new one, so the
+// documents get new ids, the references between them (account_id, category_id...) follow, and ids shared
+// by a group of documents (installment_group_id) start a new group instead of joining the original one.
+// Documents from an older schema are then upgraded by the migrations they missed.
+//
+// The restore isn't a MongoDB transaction: the unordered inserts skip documents clashing with a unique
+// index, which would abort a transaction, and a large backup outlives a transaction's time limit.
+// Instead, when a step fails the documents inserted so far are deleted again, see RestoreReport.RolledBack
+func RestoreBackup(ctx context.Context, db *mongo.Database, cfg *config.Config, r io.ReaderAt, size int64) (RestoreReport, error) {
+	report := RestoreReport{Collections: map[string]RestoreCollectionReport{}}
+
+	manifest, documents, err := readBackup(r, size)
+	if err != nil {
+		return report, err
+	}
+	report.SchemaVersion = manifest.SchemaVersion
+
+	empty, err := isEmptyDatabase(ctx, db)
+	if err != nil {
+		return report, err
+	}
+	report.Mode = "restore"
+	if !empty {
+		report.Mode = "merge"
+		remap := map[primitive.ObjectID]primitive.ObjectID{}
+		for _, collection := range documents {
+			for _, document := range collection {
+				collectObjectIDs(document, remap)
+			}
+		}
+		for _, collection := range documents {
+			for _, document := range collection {
+				remapObjectIDs(document, remap)
+			}
+		}
+	}
+
+	// Every document is inserted with its _id, so the ids attempted are enough to undo the restore:
+	// in merge mode they are all new, in restore mode the database was empty
+	attempted := map[string][]interface{}{}
+	fail := func(err error) (RestoreReport, error) {
+		cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cfg.Timeouts.Database)
+		defer cancel()
+		for name, ids := range attempted {
+			if _, cleanupErr := db.Collection(name).DeleteMany(cleanupCtx, bson.M{"_id": bson.M{"$in": ids}}); cleanupErr != nil {
+				return report, fmt.Errorf("%w; removing the restored documents failed too, the restore is partial: %v", err, cleanupErr)
+			}
+		}
+		report.RolledBack = true
+		return report, err
+	}
+
+	for name, collection := range documents {
+		if len(collection) == 0 {
+			report.Collections[name] = RestoreCollectionReport{}
+			continue
+		}
+		batch := make([]interface{}, len(collection))
+		for i, document := range collection {
+			batch[i] = document
+			for _, element := range document {
+				if element.Key == "_id" {
+					attempted[name] = append(attempted[name], element.Value)
+				}
+			}
+		}
+
+		result := RestoreCollectionReport{}
+		inserted, err := db.Collection(name).InsertMany(ctx, batch, options.InsertMany().SetOrdered(false))
+		if inserted != nil {
+			result.Inserted = len(inserted.InsertedIDs)
+		}
+		if err != nil {
+			var bulkErr mongo.BulkWriteException
+			if !errors.As(err, &bulkErr) || !mongo.IsDuplicateKeyError(err) {
+				return fail(fmt.Errorf("failed to restore %s: %w", name, err))
+			}
+			for _, writeErr := range bulkErr.WriteErrors {
+				if writeErr.Code != 11000 {
+					return fail(fmt.Errorf("failed to restore %s: %w", name, err))
+				}
+			}
+			result.Skipped = len(bulkErr.WriteErrors)
+			result.Inserted = len(collection) - result.Skipped
+		}
+		report.Collections[name] = result
+	}
+
+	if err := UpgradeRestoredData(ctx, db, cfg, manifest.SchemaVersion); err != nil {
+		return fail(err)
+	}
+	return report, nil
+}
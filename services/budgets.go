@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrOutsideBudget = errors.New("date is outside the budget period")
+
+// monthKey formats a date as the "YYYY-MM" key used by the monthly totals
+func monthKey(date time.Time) string {
+	return date.Format("2006-01")
+}
+
+func monthStart(date time.Time) time.Time {
+	date = date.UTC()
+	return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// BudgetWindow returns the [start, end) period of a budget containing the reference date
+func BudgetWindow(budget models.Budget, at time.Time) (time.Time, time.Time, error) {
+	if budget.Period == "custom" {
+		if budget.EndDate == nil {
+			return time.Time{}, time.Time{}, errors.New("custom budgets need an end_date")
+		}
+		return budget.StartDate, *budget.EndDate, nil
+	}
+
+	if at.Before(monthStart(budget.StartDate)) {
+		return time.Time{}, time.Time{}, ErrOutsideBudget
+	}
+	if budget.EndDate != nil && !at.Before(*budget.EndDate) {
+		return time.Time{}, time.Time{}, ErrOutsideBudget
+	}
+	start := monthStart(at)
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+// spentByMonth sums the expenses of the budget categories in [from, to), keyed by "YYYY-MM".
+// It reuses the dynamic report pipeline so budgets follow the same rules as /report.
+// A budget on a parent category also covers its subcategories
+func spentByMonth(ctx context.Context, db *mongo.Database, budget models.Budget, from, to time.Time) (map[string]models.Money, error) {
+	categoryIDs, err := CategoryWithDescendants(ctx, db, budget.CategoryIDs)
+	if err != nil {
+		return nil, err
+	}
+	categories := make([]interface{}, 0, len(categoryIDs))
+	for _, id := range categoryIDs {
+		categories = append(categories, id.Hex())
+	}
+
+	pipeline, err := BuildAggregationPipeline(models.AggregationRequest{
+		Filters: []models.Filter{
+			{Field: "category_id", Operator: "in", Value: categories},
+			{Field: "type", Operator: "eq", Value: "expense"},
+			{Field: "date", Operator: "gte", Value: from.Format(time.RFC3339)},
+			{Field: "date", Operator: "lt", Value: to.Format(time.RFC3339)},
+		},
+		GroupBy: []string{"date:year", "date:month"},
+		Metrics: []models.Metric{{Name: "spent", Operation: "sum", Field: "amount"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := db.Collection("transactions").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		Year  int          `bson:"year"`
+		Month int          `bson:"month"`
+		Spent models.Money `bson:"spent"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	spent := map[string]models.Money{}
+	for _, row := range rows {
+		spent[fmt.Sprintf("%04d-%02d", row.Year, row.Month)] = row.Spent
+	}
+	return spent, nil
+}
+
+// ComputeBudgetProgress computes spent, remaining and percent used for the budget period containing `at`.
+// With rollover, the money left unspent in each previous month is added to the next one
+func ComputeBudgetProgress(ctx context.Context, db *mongo.Database, budget models.Budget, at time.Time) (models.BudgetProgress, error) {
+	start, end, err := BudgetWindow(budget, at)
+	if err != nil {
+		return models.BudgetProgress{}, err
+	}
+
+	from := start
+	if budget.Period == "monthly" && budget.Rollover {
+		from = monthStart(budget.StartDate)
+	}
+	spent, err := spentByMonth(ctx, db, budget, from, end)
+	if err != nil {
+		return models.BudgetProgress{}, err
+	}
+
+	progress := models.BudgetProgress{
+		BudgetID:    budget.ID,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Amount:      budget.Amount,
+	}
+
+	if budget.Period == "monthly" && budget.Rollover {
+		for month := from; month.Before(start); month = month.AddDate(0, 1, 0) {
+			left := budget.Amount + progress.RolledOver - spent[monthKey(month)]
+			progress.RolledOver = max(left, 0)
+		}
+	}
+
+	if budget.Period == "custom" {
+		for _, value := range spent {
+			progress.Spent += value
+		}
+	} else {
+		progress.Spent = spent[monthKey(start)]
+	}
+
+	progress.Available = progress.Amount + progress.RolledOver
+	progress.Remaining = progress.Available - progress.Spent
+	if progress.Available > 0 {
+		progress.PercentUsed = math.Round(progress.Spent.Float64()/progress.Available.Float64()*10000) / 100
+	}
+	return progress, nil
+}
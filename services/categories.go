@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrCategoryCycle      = errors.New("a category can't be its own ancestor")
+	ErrParentNotFound     = errors.New("parent category not found")
+	ErrCategoryTypeMixed  = errors.New("subcategories must have the same type as their parent")
+	ErrCategoryTreeBroken = errors.New("category tree is deeper than supported")
+)
+
+// maxCategoryDepth guards the ancestor walk against a tree corrupted outside the API
+const maxCategoryDepth = 100
+
+// ValidateCategoryHierarchy checks that the parent of a category exists, has the same type
+// and isn't the category itself or one of its descendants. Existing subcategories must keep the type
+func ValidateCategoryHierarchy(ctx context.Context, db *mongo.Database, category models.Category) error {
+	col := db.Collection("categories")
+
+	if !category.ID.IsZero() {
+		mixed, err := col.CountDocuments(ctx, utils.NotDeleted(bson.M{"parent_id": category.ID, "type": bson.M{"$ne": category.Type}}))
+		if err != nil {
+			return err
+		}
+		if mixed > 0 {
+			return ErrCategoryTypeMixed
+		}
+	}
+
+	if category.ParentID.IsZero() {
+		return nil
+	}
+
+	ancestorID := category.ParentID
+	for depth := 0; !ancestorID.IsZero(); depth++ {
+		if depth > maxCategoryDepth {
+			return ErrCategoryTreeBroken
+		}
+		if ancestorID == category.ID {
+			return ErrCategoryCycle
+		}
+		var ancestor models.Category
+		if err := col.FindOne(ctx, utils.NotDeleted(bson.M{"_id": ancestorID})).Decode(&ancestor); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) && depth == 0 {
+				return ErrParentNotFound
+			}
+			return err
+		}
+		if depth == 0 && ancestor.Type != category.Type {
+			return ErrCategoryTypeMixed
+		}
+		ancestorID = ancestor.ParentID
+	}
+	return nil
+}
+
+// IsCategoryHierarchyError reports whether err is a validation error of the category tree
+func IsCategoryHierarchyError(err error) bool {
+	return errors.Is(err, ErrCategoryCycle) || errors.Is(err, ErrParentNotFound) || errors.Is(err, ErrCategoryTypeMixed)
+}
+
+// CategoryTree returns the top-level categories with their subcategories nested, sorted by name.
+// A category whose parent is gone is shown at the top level
+func CategoryTree(ctx context.Context, db *mongo.Database) ([]models.CategoryNode, error) {
+	cursor, err := db.Collection("categories").Find(ctx, utils.NotDeleted(bson.M{}))
+	if err != nil {
+		return nil, err
+	}
+	var categories []models.Category
+	if err := cursor.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+
+	known := map[primitive.ObjectID]bool{}
+	for _, category := range categories {
+		known[category.ID] = true
+	}
+	children := map[primitive.ObjectID][]models.Category{}
+	roots := []models.Category{}
+	for _, category := range categories {
+		if category.ParentID.IsZero() || !known[category.ParentID] {
+			roots = append(roots, category)
+			continue
+		}
+		children[category.ParentID] = append(children[category.ParentID], category)
+	}
+
+	var build func(level []models.Category) []models.CategoryNode
+	build = func(level []models.Category) []models.CategoryNode {
+		sort.Slice(level, func(i, j int) bool { return level[i].Name < level[j].Name })
+		nodes := make([]models.CategoryNode, 0, len(level))
+		for _, category := range level {
+			nodes = append(nodes, models.CategoryNode{Category: category, Children: build(children[category.ID])})
+		}
+		return nodes
+	}
+	return build(roots), nil
+}
+
+// CategoryWithDescendants returns the given categories and all their subcategories
+func CategoryWithDescendants(ctx context.Context, db *mongo.Database, ids []primitive.ObjectID) ([]primitive.ObjectID, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"_id": bson.M{"$in": ids}}}},
+		{{Key: "$graphLookup", Value: bson.M{
+			"from":             "categories",
+			"startWith":        "$_id",
+			"connectFromField": "_id",
+			"connectToField":   "parent_id",
+			"as":               "descendants",
+			"maxDepth":         maxCategoryDepth,
+		}}},
+	}
+	cursor, err := db.Collection("categories").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var results []struct {
+		Descendants []models.Category `bson:"descendants"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	seen := map[primitive.ObjectID]bool{}
+	all := []primitive.ObjectID{}
+	add := func(id primitive.ObjectID) {
+		if !seen[id] {
+			seen[id] = true
+			all = append(all, id)
+		}
+	}
+	for _, id := range ids {
+		add(id)
+	}
+	for _, result := range results {
+		for _, descendant := range result.Descendants {
+			add(descendant.ID)
+		}
+	}
+	return all, nil
+}
+
+// categoryRollupStages replaces category_id with its ancestor at the given depth of the tree.
+// $graphLookup walks up from the category: depth 0 is the category itself and the highest depth the
+// top-level one, so the ancestor at tree level L sits at depth max-L. Shallower categories stay as they are
+func categoryRollupStages(level int) mongo.Pipeline {
+	lookup := bson.D{{Key: "$graphLookup", Value: bson.M{
+		"from":             "categories",
+		"startWith":        "$category_id",
+		"connectFromField": "parent_id",
+		"connectToField":   "_id",
+		"as":               "_ancestors",
+		"depthField":       "_depth",
+		"maxDepth":         maxCategoryDepth,
+	}}}
+
+	targetDepth := bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{bson.M{"$max": "$_ancestors._depth"}, level}}}}
+	ancestor := bson.M{"$first": bson.M{"$filter": bson.M{
+		"input": "$_ancestors",
+		"cond":  bson.M{"$eq": bson.A{"$$this._depth", targetDepth}},
+	}}}
+	set := bson.D{{Key: "$set", Value: bson.M{
+		"category_id": bson.M{"$ifNull": bson.A{bson.M{"$let": bson.M{
+			"vars": bson.M{"ancestor": ancestor},
+			"in":   "$$ancestor._id",
+		}}, "$category_id"}},
+	}}}
+
+	return mongo.Pipeline{lookup, set, {{Key: "$unset", Value: "_ancestors"}}}
+}
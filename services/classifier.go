@@ -0,0 +1,319 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// classifierMaxAge is how long a trained classifier is reused before it learns from the new transactions
+const classifierMaxAge = 10 * time.Minute
+
+// Confidences only compare the trained candidates, so with a single one every known word scores 1.
+// Suggestions are applied automatically once the classifier has seen this much of the transaction type
+const (
+	minAutoCategorizeCategories = 2
+	minAutoCategorizeDocuments  = 20
+)
+
+var diacriticFolds = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "ä", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "õ", "o", "ö", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ç", "c", "ñ", "n",
+)
+
+// CategorySuggestion is a category the classifier proposes for a description
+type CategorySuggestion struct {
+	CategoryID primitive.ObjectID `json:"category_id"`
+	Name       string             `json:"name"`
+	Confidence float64            `json:"confidence"` // Posterior probability among the candidate categories, 0 to 1
+}
+
+// CategoryClassifier is a multinomial naive Bayes model over description tokens
+type CategoryClassifier struct {
+	documents   int
+	categories  map[primitive.ObjectID]int            // Transactions per category
+	tokenCounts map[primitive.ObjectID]map[string]int // Token occurrences per category
+	tokenTotals map[primitive.ObjectID]int            // All token occurrences per category
+	vocabulary  map[string]struct{}
+	trainedAt   time.Time
+}
+
+var classifierCache struct {
+	sync.Mutex
+	classifier *CategoryClassifier
+}
+
+// tokenize lowercases a description, folds the Portuguese diacritics and splits it into words.
+// Numbers and single letters (card endings, dates, installment counters) carry no meaning and are dropped
+func tokenize(description string) []string {
+	folded := diacriticFolds.Replace(strings.ToLower(description))
+	words := strings.FieldsFunc(folded, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := make([]string, 0, len(words))
+	for _, word := range words {
+		if len(word) < 2 || strings.IndexFunc(word, func(r rune) bool { return !unicode.IsDigit(r) }) < 0 {
+			continue
+		}
+		tokens = append(tokens, word)
+	}
+	return tokens
+}
+
+// TrainClassifier learns from every categorized income and expense transaction
+func TrainClassifier(ctx context.Context, db *mongo.Database) (*CategoryClassifier, error) {
+	classifier := &CategoryClassifier{
+		categories:  map[primitive.ObjectID]int{},
+		tokenCounts: map[primitive.ObjectID]map[string]int{},
+		tokenTotals: map[primitive.ObjectID]int{},
+		vocabulary:  map[string]struct{}{},
+		trainedAt:   time.Now(),
+	}
+
+	filter := utils.NotDeleted(bson.M{
+		"category_id": bson.M{"$exists": true},
+		"type":        bson.M{"$in": bson.A{"income", "expense"}},
+	})
+	opts := options.Find().SetProjection(bson.M{"description": 1, "category_id": 1})
+	cursor, err := db.Collection("transactions").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var transaction models.Transaction
+		if err := cursor.Decode(&transaction); err != nil {
+			return nil, err
+		}
+		classifier.learn(transaction.Description, transaction.CategoryID)
+	}
+	return classifier, cursor.Err()
+}
+
+func (cl *CategoryClassifier) learn(description string, categoryID primitive.ObjectID) {
+	tokens := tokenize(description)
+	if len(tokens) == 0 || categoryID.IsZero() {
+		return
+	}
+	cl.documents++
+	cl.categories[categoryID]++
+	if cl.tokenCounts[categoryID] == nil {
+		cl.tokenCounts[categoryID] = map[string]int{}
+	}
+	for _, token := range tokens {
+		cl.tokenCounts[categoryID][token]++
+		cl.tokenTotals[categoryID]++
+		cl.vocabulary[token] = struct{}{}
+	}
+}
+
+// Predict scores the candidate categories for a description, best first.
+// Tokens never seen in training are ignored; without any known token there is nothing to suggest
+func (cl *CategoryClassifier) Predict(description string, candidates map[primitive.ObjectID]string) []CategorySuggestion {
+	tokens := []string{}
+	for _, token := range tokenize(description) {
+		if _, ok := cl.vocabulary[token]; ok {
+			tokens = append(tokens, token)
+		}
+	}
+	if len(tokens) == 0 || cl.documents == 0 {
+		return []CategorySuggestion{}
+	}
+
+	// Log-probabilities with Laplace smoothing, turned into confidences with a softmax
+	vocabularySize := float64(len(cl.vocabulary))
+	scores := map[primitive.ObjectID]float64{}
+	best := math.Inf(-1)
+	for categoryID, documents := range cl.categories {
+		if _, ok := candidates[categoryID]; !ok {
+			continue
+		}
+		score := math.Log(float64(documents) / float64(cl.documents))
+		denominator := float64(cl.tokenTotals[categoryID]) + vocabularySize
+		for _, token := range tokens {
+			score += math.Log((float64(cl.tokenCounts[categoryID][token]) + 1) / denominator)
+		}
+		scores[categoryID] = score
+		best = math.Max(best, score)
+	}
+
+	total := 0.0
+	for _, score := range scores {
+		total += math.Exp(score - best)
+	}
+	suggestions := make([]CategorySuggestion, 0, len(scores))
+	for categoryID, score := range scores {
+		suggestions = append(suggestions, CategorySuggestion{
+			CategoryID: categoryID,
+			Name:       candidates[categoryID],
+			Confidence: math.Exp(score-best) / total,
+		})
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		return suggestions[i].Confidence > suggestions[j].Confidence
+	})
+	return suggestions
+}
+
+// trainedOn counts the candidate categories the classifier learned from, and their training transactions
+func (cl *CategoryClassifier) trainedOn(candidates map[primitive.ObjectID]string) (categories int, documents int) {
+	for categoryID, count := range cl.categories {
+		if _, ok := candidates[categoryID]; ok {
+			categories++
+			documents += count
+		}
+	}
+	return categories, documents
+}
+
+// loadClassifier returns the cached classifier, training a new one when it is too old
+func loadClassifier(ctx context.Context, db *mongo.Database) (*CategoryClassifier, error) {
+	classifierCache.Lock()
+	defer classifierCache.Unlock()
+
+	if classifierCache.classifier != nil && time.Since(classifierCache.classifier.trainedAt) < classifierMaxAge {
+		return classifierCache.classifier, nil
+	}
+	classifier, err := TrainClassifier(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	classifierCache.classifier = classifier
+	return classifier, nil
+}
+
+// suggestionCandidates maps the ids of the categories of a transaction type (all when empty) to their names
+func suggestionCandidates(ctx context.Context, db *mongo.Database, transactionType string) (map[primitive.ObjectID]string, error) {
+	filter := utils.NotDeleted(bson.M{})
+	if transactionType != "" {
+		filter["type"] = transactionType
+	}
+	cursor, err := db.Collection("categories").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var categories []models.Category
+	if err := cursor.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+
+	candidates := make(map[primitive.ObjectID]string, len(categories))
+	for _, category := range categories {
+		candidates[category.ID] = category.Name
+	}
+	return candidates, nil
+}
+
+// SuggestCategories ranks the categories of transactionType for a description, at most limit of them
+func SuggestCategories(ctx context.Context, db *mongo.Database, description string, transactionType string, limit int) ([]CategorySuggestion, error) {
+	classifier, err := loadClassifier(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := suggestionCandidates(ctx, db, transactionType)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := classifier.Predict(description, candidates)
+	if limit > 0 && len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions, nil
+}
+
+// Categorizer applies the learned suggestions to many transactions, e.g. the rows of an import.
+// The classifier is loaded once and the candidate categories once per transaction type
+type Categorizer struct {
+	db         *mongo.Database
+	threshold  float64
+	classifier *CategoryClassifier
+	candidates map[string]map[primitive.ObjectID]string
+}
+
+// LoadCategorizer prepares a Categorizer applying suggestions whose confidence reaches threshold.
+// A threshold of zero disables it, and then nothing is loaded
+func LoadCategorizer(ctx context.Context, db *mongo.Database, threshold float64) (*Categorizer, error) {
+	categorizer := &Categorizer{db: db, threshold: threshold, candidates: map[string]map[primitive.ObjectID]string{}}
+	if threshold <= 0 {
+		return categorizer, nil
+	}
+	classifier, err := loadClassifier(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	categorizer.classifier = classifier
+	return categorizer, nil
+}
+
+// Pick returns the category that would be applied to a transaction of that type and description, if any:
+// the best suggestion when its confidence reaches the threshold. Nothing is picked until the classifier
+// learned from enough categories and transactions of the type
+func (ct *Categorizer) Pick(ctx context.Context, description string, transactionType string) (primitive.ObjectID, bool, error) {
+	if ct.classifier == nil || transactionType == "transfer" {
+		return primitive.NilObjectID, false, nil
+	}
+	candidates, ok := ct.candidates[transactionType]
+	if !ok {
+		var err error
+		candidates, err = suggestionCandidates(ctx, ct.db, transactionType)
+		if err != nil {
+			return primitive.NilObjectID, false, err
+		}
+		// Too little training leaves the type without candidates, so it isn't checked again
+		if categories, documents := ct.classifier.trainedOn(candidates); categories < minAutoCategorizeCategories || documents < minAutoCategorizeDocuments {
+			candidates = nil
+		}
+		ct.candidates[transactionType] = candidates
+	}
+	if len(candidates) == 0 {
+		return primitive.NilObjectID, false, nil
+	}
+	suggestions := ct.classifier.Predict(description, candidates)
+	if len(suggestions) == 0 || suggestions[0].Confidence < ct.threshold {
+		return primitive.NilObjectID, false, nil
+	}
+	return suggestions[0].CategoryID, true, nil
+}
+
+// Apply gives an uncategorized income or expense the category Pick chooses for it
+func (ct *Categorizer) Apply(ctx context.Context, transaction *models.Transaction) error {
+	if !transaction.CategoryID.IsZero() {
+		return nil
+	}
+	categoryID, ok, err := ct.Pick(ctx, transaction.Description, transaction.Type)
+	if err != nil || !ok {
+		return err
+	}
+	transaction.CategoryID = categoryID
+	return nil
+}
+
+// AutoCategorize applies the learned suggestions to a single transaction, see Categorizer.Apply
+func AutoCategorize(ctx context.Context, db *mongo.Database, transaction *models.Transaction, threshold float64) error {
+	if threshold <= 0 || !transaction.CategoryID.IsZero() || transaction.Type == "transfer" {
+		return nil
+	}
+	categorizer, err := LoadCategorizer(ctx, db, threshold)
+	if err != nil {
+		return err
+	}
+	return categorizer.Apply(ctx, transaction)
+}
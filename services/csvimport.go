@@ -0,0 +1,363 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/config"
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ValidateImportProfile checks that a profile maps every column its sign convention needs
+func ValidateImportProfile(profile models.ImportProfile) error {
+	if profile.DecimalSeparator != "." && profile.DecimalSeparator != "," {
+		return errors.New(`decimal_separator must be "." or ","`)
+	}
+	if profile.Delimiter == profile.DecimalSeparator && profile.Delimiter != "" {
+		return errors.New("delimiter and decimal_separator must differ")
+	}
+
+	columns := profile.Columns
+	switch profile.SignConvention {
+	case "debit_credit":
+		if columns.Debit == "" || columns.Credit == "" {
+			return errors.New("the debit_credit convention needs the debit and credit columns")
+		}
+	case "type_column":
+		if columns.Amount == "" || columns.Type == "" {
+			return errors.New("the type_column convention needs the amount and type columns")
+		}
+		if len(profile.IncomeValues) == 0 || len(profile.ExpenseValues) == 0 {
+			return errors.New("the type_column convention needs income_values and expense_values")
+		}
+	default:
+		if columns.Amount == "" {
+			return fmt.Errorf("the %s convention needs the amount column", profile.SignConvention)
+		}
+	}
+
+	for _, column := range []string{columns.Date, columns.Description, columns.Amount, columns.Debit, columns.Credit, columns.Type, columns.ExternalID} {
+		if column == "" {
+			continue
+		}
+		if position, err := strconv.Atoi(column); err == nil {
+			if position < 1 {
+				return fmt.Errorf("invalid column position %d", position)
+			}
+		} else if !profile.HasHeader {
+			return fmt.Errorf("column '%s' is referenced by name but the profile has no header", column)
+		}
+	}
+	return nil
+}
+
+// csvColumns resolves the profile columns to record indexes, -1 when unmapped
+type csvColumns struct {
+	date, description, amount, debit, credit, kind, externalID int
+}
+
+func resolveColumns(profile models.ImportProfile, header []string) (csvColumns, error) {
+	resolve := func(column string) (int, error) {
+		if column == "" {
+			return -1, nil
+		}
+		if position, err := strconv.Atoi(column); err == nil {
+			return position - 1, nil
+		}
+		for i, name := range header {
+			if strings.EqualFold(strings.TrimSpace(name), strings.TrimSpace(column)) {
+				return i, nil
+			}
+		}
+		return -1, fmt.Errorf("column '%s' not found in the header", column)
+	}
+
+	var resolved csvColumns
+	var err error
+	targets := []struct {
+		column string
+		index  *int
+	}{
+		{profile.Columns.Date, &resolved.date},
+		{profile.Columns.Description, &resolved.description},
+		{profile.Columns.Amount, &resolved.amount},
+		{profile.Columns.Debit, &resolved.debit},
+		{profile.Columns.Credit, &resolved.credit},
+		{profile.Columns.Type, &resolved.kind},
+		{profile.Columns.ExternalID, &resolved.externalID},
+	}
+	for _, target := range targets {
+		if *target.index, err = resolve(target.column); err != nil {
+			return resolved, err
+		}
+	}
+	return resolved, nil
+}
+
+// field returns the trimmed value at index, or "" when the column is unmapped or missing from the record
+func field(record []string, index int) string {
+	if index < 0 || index >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[index])
+}
+
+// ParseProfileAmount reads an amount written with the profile's decimal separator.
+// Currency symbols, spaces and thousands separators are dropped, and "(10.00)" is negative
+func ParseProfileAmount(value string, decimalSeparator string) (models.Money, error) {
+	value = strings.TrimSpace(value)
+	negative := strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")")
+
+	var cleaned strings.Builder
+	for _, r := range value {
+		switch {
+		case r >= '0' && r <= '9', r == '-', r == '+':
+			cleaned.WriteRune(r)
+		case string(r) == decimalSeparator:
+			cleaned.WriteRune('.')
+		}
+	}
+	number := cleaned.String()
+	// Some banks write the sign after the number: "1.234,56-"
+	if strings.HasSuffix(number, "-") {
+		number = "-" + strings.TrimSuffix(number, "-")
+	}
+	amount, err := models.ParseMoney(number)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount '%s'", value)
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// transactionFromCSV maps a record to a transaction following the profile's sign convention
+func transactionFromCSV(record []string, columns csvColumns, profile models.ImportProfile) (models.Transaction, error) {
+	transaction := models.Transaction{
+		Description: field(record, columns.description),
+		CategoryID:  profile.DefaultCategory,
+		ExternalID:  field(record, columns.externalID),
+	}
+
+	// Without a layout of its own the profile reads dates like the rest of the API
+	var date time.Time
+	var err error
+	if profile.DateLayout == "" {
+		date, err = utils.ParseDateToISO(field(record, columns.date))
+	} else {
+		date, err = utils.ParseDateWithLayout(field(record, columns.date), profile.DateLayout)
+	}
+	if err != nil {
+		return transaction, fmt.Errorf("invalid date '%s': %w", field(record, columns.date), err)
+	}
+	transaction.Date = date
+
+	var amount models.Money
+	switch profile.SignConvention {
+	case "debit_credit":
+		if debit := field(record, columns.debit); debit != "" {
+			if amount, err = ParseProfileAmount(debit, profile.DecimalSeparator); err != nil {
+				return transaction, err
+			}
+			amount = -absMoney(amount)
+		} else if credit := field(record, columns.credit); credit != "" {
+			if amount, err = ParseProfileAmount(credit, profile.DecimalSeparator); err != nil {
+				return transaction, err
+			}
+			amount = absMoney(amount)
+		}
+	case "type_column":
+		if amount, err = ParseProfileAmount(field(record, columns.amount), profile.DecimalSeparator); err != nil {
+			return transaction, err
+		}
+		kind := field(record, columns.kind)
+		switch {
+		case containsFold(profile.IncomeValues, kind):
+			amount = absMoney(amount)
+		case containsFold(profile.ExpenseValues, kind):
+			amount = -absMoney(amount)
+		default:
+			return transaction, fmt.Errorf("unknown type '%s'", kind)
+		}
+	default:
+		if amount, err = ParseProfileAmount(field(record, columns.amount), profile.DecimalSeparator); err != nil {
+			return transaction, err
+		}
+		if profile.SignConvention == "negative_income" {
+			amount = -amount
+		}
+	}
+
+	switch {
+	case amount > 0:
+		transaction.Type = "income"
+		transaction.Amount = amount
+	case amount < 0:
+		transaction.Type = "expense"
+		transaction.Amount = -amount
+	default:
+		return transaction, errors.New("zero or missing amount")
+	}
+	return transaction, nil
+}
+
+func absMoney(amount models.Money) models.Money {
+	if amount < 0 {
+		return -amount
+	}
+	return amount
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// syntheticExternalID identifies a CSV row without a bank id, so re-importing the same file skips it.
+// seen tells apart identical rows of the same file, like two equal coffees on the same day
+func syntheticExternalID(transaction models.Transaction, seen map[string]int) string {
+	key := fmt.Sprintf("%s|%d|%s|%s", transaction.Date.Format("2006-01-02"), transaction.Amount, transaction.Type, transaction.Description)
+	seen[key]++
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s|%d", key, seen[key])))
+	return "csv:" + hex.EncodeToString(sum[:])
+}
+
+// ImportCSV runs a CSV file through an import profile into accountID (or the profile's default account).
+// Without commit nothing is written and the report previews what would be created and skipped
+func ImportCSV(ctx context.Context, db *mongo.Database, profile models.ImportProfile, accountID primitive.ObjectID, file io.Reader, cfg *config.Config, commit bool) (ImportReport, error) {
+	report := newImportReport()
+	report.DryRun = !commit
+
+	if accountID.IsZero() {
+		accountID = profile.DefaultAccount
+	}
+	if accountID.IsZero() {
+		return report, errors.New("account_id is required when the profile has no default account")
+	}
+	account, err := findImportAccount(ctx, db, accountID)
+	if err != nil {
+		return report, err
+	}
+	currency := account.Currency
+	if currency == "" {
+		currency = cfg.Currency.Base
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return report, err
+	}
+	data = bytes.TrimPrefix(toUTF8(data), []byte("\ufeff"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	if profile.Delimiter != "" {
+		reader.Comma = rune(profile.Delimiter[0])
+	}
+	records, err := reader.ReadAll()
+	if err != nil {
+		return report, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	start := profile.SkipRows
+	var header []string
+	if profile.HasHeader {
+		if start >= len(records) {
+			return report, errors.New("header row not found")
+		}
+		header = records[start]
+		start++
+	}
+	columns, err := resolveColumns(profile, header)
+	if err != nil {
+		return report, err
+	}
+
+	rules, err := LoadRuleSet(ctx, db)
+	if err != nil {
+		return report, err
+	}
+	categorizer, err := LoadCategorizer(ctx, db, cfg.Classifier.AutoApplyThreshold)
+	if err != nil {
+		return report, err
+	}
+
+	now := time.Now()
+	seen := map[string]int{}
+	imported := map[string]bool{}
+	for i := start; i < len(records); i++ {
+		row := i + 1
+		if strings.TrimSpace(strings.Join(records[i], "")) == "" {
+			continue
+		}
+
+		transaction, err := transactionFromCSV(records[i], columns, profile)
+		if err != nil {
+			failed := importRowFor(row, transaction)
+			failed.Reason = err.Error()
+			report.Failed = append(report.Failed, failed)
+			continue
+		}
+		transaction.Account = account.ID
+		transaction.Currency = currency
+		if transaction.ExternalID == "" {
+			transaction.ExternalID = syntheticExternalID(transaction, seen)
+		}
+		// After the synthetic id, so re-importing the file still skips rows whose rules changed
+		rules.Apply(&transaction, false)
+		if err := categorizer.Apply(ctx, &transaction); err != nil {
+			return report, err
+		}
+		result := importRowFor(row, transaction)
+
+		if !commit {
+			exists, err := alreadyImported(ctx, db, transaction)
+			switch {
+			case err != nil:
+				result.Reason = err.Error()
+				report.Failed = append(report.Failed, result)
+			case exists || imported[transaction.ExternalID]:
+				result.Reason = "already imported"
+				report.Skipped = append(report.Skipped, result)
+			default:
+				imported[transaction.ExternalID] = true
+				report.Created = append(report.Created, result)
+			}
+			continue
+		}
+
+		transaction.ID = primitive.NewObjectID()
+		transaction.CreatedAt = now
+		created, err := importTransaction(ctx, db, transaction)
+		switch {
+		case err != nil:
+			result.Reason = err.Error()
+			report.Failed = append(report.Failed, result)
+		case !created:
+			result.Reason = "already imported"
+			report.Skipped = append(report.Skipped, result)
+		default:
+			result.TransactionID = transaction.ID.Hex()
+			report.Created = append(report.Created, result)
+		}
+	}
+	return report, nil
+}
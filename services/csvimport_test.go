@@ -0,0 +1,49 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/1v4n-ML/finance-tracker-api/models"
+)
+
+func TestParseProfileAmount(t *testing.T) {
+	tests := []struct {
+		value            string
+		decimalSeparator string
+		want             models.Money
+		wantErr          bool
+	}{
+		{"1.234,56-", ",", -123456, false}, // Trailing sign
+		{"1.234,56", ",", 123456, false},
+		{"-1.234,56", ",", -123456, false},
+		{"R$ 1.234,56", ",", 123456, false},
+		{"0,29", ",", 29, false},
+		{"(10.00)", ".", -1000, false}, // Accounting negative
+		{"(1,234.50)", ".", -123450, false},
+		{"$1,234.50", ".", 123450, false},
+		{" 42 ", ".", 4200, false},
+		{"+7.5", ".", 750, false},
+		{"1.234,567", ",", 0, true},
+		{"", ",", 0, true},
+		{"R$", ",", 0, true},
+		{"abc", ".", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseProfileAmount(tt.value, tt.decimalSeparator)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseProfileAmount(%q) = %d, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseProfileAmount(%q) failed: %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseProfileAmount(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var pairPattern = regexp.MustCompile(`^[A-Z]{3}/[A-Z]{3}$`)
+
+// ApplyAccountCurrency sets the transaction currency from its account (or the base currency when it has none)
+// and checks that a transfer between accounts in different currencies says how much the destination receives
+func ApplyAccountCurrency(ctx context.Context, db *mongo.Database, transaction *models.Transaction, baseCurrency string) error {
+	accountsCol := db.Collection("accounts")
+
+	currency := baseCurrency
+	if !transaction.Account.IsZero() {
+		var account models.Account
+		if err := accountsCol.FindOne(ctx, utils.NotDeleted(bson.M{"_id": transaction.Account})).Decode(&account); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return errors.New("account not found")
+			}
+			return err
+		}
+		if account.Currency != "" {
+			currency = account.Currency
+		}
+	}
+	if transaction.Currency != "" && transaction.Currency != currency {
+		return fmt.Errorf("transaction currency %s doesn't match the account currency %s", transaction.Currency, currency)
+	}
+	transaction.Currency = currency
+
+	if transaction.Type != "transfer" {
+		transaction.DestinationAmount = 0
+		return nil
+	}
+
+	var destination models.Account
+	if err := accountsCol.FindOne(ctx, utils.NotDeleted(bson.M{"_id": transaction.DestinationAccount})).Decode(&destination); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return errors.New("destination account not found")
+		}
+		return err
+	}
+	destinationCurrency := destination.Currency
+	if destinationCurrency == "" {
+		destinationCurrency = baseCurrency
+	}
+	if destinationCurrency == currency {
+		transaction.DestinationAmount = 0
+		return nil
+	}
+	if transaction.DestinationAmount <= 0 {
+		return fmt.Errorf("destination_amount in %s is required for transfers from %s", destinationCurrency, currency)
+	}
+	return nil
+}
+
+// ParsePair validates a "USD/BRL" currency pair and returns its two currencies
+func ParsePair(pair string) (string, string, error) {
+	pair = strings.ToUpper(strings.TrimSpace(pair))
+	if !pairPattern.MatchString(pair) {
+		return "", "", fmt.Errorf("invalid currency pair '%s' (expected e.g. USD/BRL)", pair)
+	}
+	from, to, _ := strings.Cut(pair, "/")
+	if from == to {
+		return "", "", fmt.Errorf("invalid currency pair '%s'", pair)
+	}
+	return from, to, nil
+}
+
+// UpsertExchangeRate stores the rate of a pair for a day, replacing the one already stored for that day.
+// It reports whether a new rate was inserted
+func UpsertExchangeRate(ctx context.Context, db *mongo.Database, rate models.ExchangeRate) (bool, error) {
+	from, to, err := ParsePair(rate.Pair)
+	if err != nil {
+		return false, err
+	}
+	if rate.Rate <= 0 {
+		return false, errors.New("rate must be positive")
+	}
+	date := rate.Date.UTC()
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	now := time.Now()
+
+	result, err := db.Collection("exchange_rates").UpdateOne(ctx,
+		bson.M{"pair": from + "/" + to, "date": day},
+		bson.M{
+			"$set":         bson.M{"rate": rate.Rate, "updated_at": now},
+			"$setOnInsert": bson.M{"created_at": now},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.UpsertedCount > 0, nil
+}
+
+// ExchangeRateImportReport summarizes a CSV upload
+type ExchangeRateImportReport struct {
+	Inserted int               `json:"inserted"`
+	Updated  int               `json:"updated"`
+	Failed   []ImportLineError `json:"failed"`
+}
+
+// ImportLineError points at a line of an uploaded file that couldn't be imported
+type ImportLineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// ImportExchangeRatesCSV reads "date,pair,rate" lines (an optional header is skipped).
+// Dates are YYYY-MM-DD and rates use a dot as decimal separator
+func ImportExchangeRatesCSV(ctx context.Context, db *mongo.Database, file io.Reader) (ExchangeRateImportReport, error) {
+	report := ExchangeRateImportReport{Failed: []ImportLineError{}}
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	line := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			report.Failed = append(report.Failed, ImportLineError{Line: line, Error: err.Error()})
+			continue
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "date") {
+			continue
+		}
+		if len(record) != 3 {
+			report.Failed = append(report.Failed, ImportLineError{Line: line, Error: "expected 3 columns: date,pair,rate"})
+			continue
+		}
+
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(record[0]))
+		if err != nil {
+			report.Failed = append(report.Failed, ImportLineError{Line: line, Error: "invalid date, expected YYYY-MM-DD"})
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+		if err != nil {
+			report.Failed = append(report.Failed, ImportLineError{Line: line, Error: "invalid rate"})
+			continue
+		}
+
+		inserted, err := UpsertExchangeRate(ctx, db, models.ExchangeRate{Date: date, Pair: record[1], Rate: value})
+		if err != nil {
+			report.Failed = append(report.Failed, ImportLineError{Line: line, Error: err.Error()})
+			continue
+		}
+		if inserted {
+			report.Inserted++
+		} else {
+			report.Updated++
+		}
+	}
+	return report, nil
+}
+
+// currencyConversionStages converts "amount" into the target currency with the latest rate on or before
+// the transaction date. Both the direct pair (USD/BRL) and the inverse one (BRL/USD) are used.
+// Amounts without any known rate become null and are left out of sums and averages
+func currencyConversionStages(target string) mongo.Pipeline {
+	direct := bson.M{"$concat": bson.A{"$$currency", "/" + target}}
+	inverse := bson.M{"$concat": bson.A{target + "/", "$$currency"}}
+
+	lookup := bson.D{{Key: "$lookup", Value: bson.M{
+		"from": "exchange_rates",
+		"let":  bson.M{"currency": "$currency", "date": "$date"},
+		"pipeline": bson.A{
+			bson.M{"$match": bson.M{"$expr": bson.M{"$and": bson.A{
+				bson.M{"$in": bson.A{"$pair", bson.A{direct, inverse}}},
+				bson.M{"$lte": bson.A{"$date", "$$date"}},
+			}}}},
+			bson.M{"$sort": bson.M{"date": -1}},
+			bson.M{"$limit": 1},
+		},
+		"as": "_rate",
+	}}}
+
+	factor := bson.M{"$cond": bson.A{
+		bson.M{"$eq": bson.A{"$$rate.pair", bson.M{"$concat": bson.A{"$currency", "/" + target}}}},
+		"$$rate.rate",
+		bson.M{"$divide": bson.A{1, "$$rate.rate"}},
+	}}
+	converted := bson.M{"$let": bson.M{
+		"vars": bson.M{"rate": bson.M{"$first": "$_rate"}},
+		"in": bson.M{"$cond": bson.A{
+			bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{"$$rate", nil}}, nil}},
+			nil,
+			bson.M{"$toLong": bson.M{"$round": bson.A{bson.M{"$multiply": bson.A{"$amount", factor}}, 0}}},
+		}},
+	}}
+
+	set := bson.D{{Key: "$set", Value: bson.M{
+		"amount": bson.M{"$cond": bson.A{
+			bson.M{"$eq": bson.A{"$currency", target}},
+			"$amount",
+			converted,
+		}},
+		"currency": target,
+	}}}
+
+	return mongo.Pipeline{lookup, set, {{Key: "$unset", Value: "_rate"}}}
+}
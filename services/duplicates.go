@@ -0,0 +1,314 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	DefaultDuplicateWindowDays = 3   // Dates further apart than this are never duplicates
+	DefaultDuplicateMinScore   = 0.6 // Minimum score for two transactions to be reported as duplicates
+)
+
+var (
+	// ErrNotDuplicates is returned when merging transactions that don't share account, amount and type
+	ErrNotDuplicates = errors.New("only transactions with the same account, amount and type can be merged")
+	// ErrNothingToMerge is returned when the transactions to merge are only the kept one
+	ErrNothingToMerge = errors.New("transaction_ids must hold at least one transaction besides keep_id")
+)
+
+// DuplicateGroup is a set of transactions that likely record the same purchase.
+// Score is the average score of the pairs that linked them, from 0 to 1
+type DuplicateGroup struct {
+	Score        float64              `json:"score"`
+	Transactions []models.Transaction `json:"transactions"`
+}
+
+// descriptionSimilarity is the Dice coefficient of the description tokens, so word order and accents don't matter
+func descriptionSimilarity(a, b string) float64 {
+	tokensA, tokensB := map[string]bool{}, map[string]bool{}
+	for _, token := range tokenize(a) {
+		tokensA[token] = true
+	}
+	for _, token := range tokenize(b) {
+		tokensB[token] = true
+	}
+	if len(tokensA) == 0 || len(tokensB) == 0 {
+		if diacriticFolds.Replace(a) == diacriticFolds.Replace(b) {
+			return 1
+		}
+		return 0
+	}
+
+	common := 0
+	for token := range tokensA {
+		if tokensB[token] {
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(tokensA)+len(tokensB))
+}
+
+// duplicateScore rates how likely two transactions are the same one: the description weighs 70%
+// and the date distance 30%. Different accounts, amounts or types, or dates outside the window, score 0
+func duplicateScore(a, b models.Transaction, windowDays int) float64 {
+	if a.Account != b.Account || a.Amount != b.Amount || a.Type != b.Type {
+		return 0
+	}
+	days := math.Abs(a.Date.Sub(b.Date).Hours()) / 24
+	if days > float64(windowDays) {
+		return 0
+	}
+	dateScore := 1 - days/float64(windowDays+1)
+	return 0.7*descriptionSimilarity(a.Description, b.Description) + 0.3*dateScore
+}
+
+func pairKey(a, b primitive.ObjectID) string {
+	if a.Hex() > b.Hex() {
+		a, b = b, a
+	}
+	return a.Hex() + b.Hex()
+}
+
+// dismissedPairs loads the pairs of transactions the user said aren't duplicates
+func dismissedPairs(ctx context.Context, db *mongo.Database) (map[string]bool, error) {
+	cursor, err := db.Collection("duplicate_dismissals").Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var dismissals []models.DuplicateDismissal
+	if err := cursor.All(ctx, &dismissals); err != nil {
+		return nil, err
+	}
+
+	pairs := map[string]bool{}
+	for _, dismissal := range dismissals {
+		for i, a := range dismissal.TransactionIDs {
+			for _, b := range dismissal.TransactionIDs[i+1:] {
+				pairs[pairKey(a, b)] = true
+			}
+		}
+	}
+	return pairs, nil
+}
+
+// FindDuplicates groups the transactions matching filter that likely are duplicates.
+// Candidates share account, amount and type, so MongoDB buckets them first and only
+// the transactions inside a bucket are compared
+func FindDuplicates(ctx context.Context, db *mongo.Database, filter bson.M, windowDays int, minScore float64) ([]DuplicateGroup, error) {
+	dismissed, err := dismissedPairs(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: utils.NotDeleted(filter)}},
+		{{Key: "$sort", Value: bson.D{{Key: "date", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":          bson.M{"account_id": "$account_id", "amount": "$amount", "type": "$type"},
+			"transactions": bson.M{"$push": "$$ROOT"},
+			"count":        bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	}
+	cursor, err := db.Collection("transactions").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var buckets []struct {
+		Transactions []models.Transaction `bson:"transactions"`
+	}
+	if err := cursor.All(ctx, &buckets); err != nil {
+		return nil, err
+	}
+
+	groups := []DuplicateGroup{}
+	for _, bucket := range buckets {
+		groups = append(groups, groupDuplicates(bucket.Transactions, dismissed, windowDays, minScore)...)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Score != groups[j].Score {
+			return groups[i].Score > groups[j].Score
+		}
+		return groups[i].Transactions[0].Date.After(groups[j].Transactions[0].Date)
+	})
+	return groups, nil
+}
+
+// groupDuplicates links the pairs of a date-sorted bucket scoring at least minScore and returns the connected groups
+func groupDuplicates(transactions []models.Transaction, dismissed map[string]bool, windowDays int, minScore float64) []DuplicateGroup {
+	parent := make([]int, len(transactions))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	scores := map[int][]float64{}
+	window := time.Duration(windowDays) * 24 * time.Hour
+	for i := range transactions {
+		for j := i + 1; j < len(transactions); j++ {
+			if transactions[j].Date.Sub(transactions[i].Date) > window {
+				break
+			}
+			if dismissed[pairKey(transactions[i].ID, transactions[j].ID)] {
+				continue
+			}
+			score := duplicateScore(transactions[i], transactions[j], windowDays)
+			if score < minScore {
+				continue
+			}
+			parent[find(j)] = find(i)
+			scores[j] = append(scores[j], score)
+		}
+	}
+
+	members := map[int][]int{}
+	for i := range transactions {
+		root := find(i)
+		members[root] = append(members[root], i)
+	}
+
+	groups := []DuplicateGroup{}
+	for _, indexes := range members {
+		if len(indexes) < 2 {
+			continue
+		}
+		group := DuplicateGroup{Transactions: make([]models.Transaction, 0, len(indexes))}
+		total, count := 0.0, 0
+		for _, i := range indexes {
+			group.Transactions = append(group.Transactions, transactions[i])
+			for _, score := range scores[i] {
+				total += score
+				count++
+			}
+		}
+		group.Score = math.Round(total/float64(count)*100) / 100
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// FindDuplicatesOf returns the stored transactions a new one would likely duplicate
+func FindDuplicatesOf(ctx context.Context, db *mongo.Database, transaction models.Transaction, windowDays int, minScore float64) ([]models.Transaction, error) {
+	window := time.Duration(windowDays) * 24 * time.Hour
+	filter := utils.NotDeleted(bson.M{
+		"account_id": transaction.Account,
+		"amount":     transaction.Amount,
+		"type":       transaction.Type,
+		"date":       bson.M{"$gte": transaction.Date.Add(-window), "$lte": transaction.Date.Add(window)},
+	})
+	cursor, err := db.Collection("transactions").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []models.Transaction
+	if err := cursor.All(ctx, &candidates); err != nil {
+		return nil, err
+	}
+
+	duplicates := []models.Transaction{}
+	for _, candidate := range candidates {
+		if duplicateScore(transaction, candidate, windowDays) >= minScore {
+			duplicates = append(duplicates, candidate)
+		}
+	}
+	return duplicates, nil
+}
+
+// MergeDuplicates keeps one transaction of a duplicate group and deletes the others, reverting them from
+// the balances. The kept one inherits the category, tags and bank id it lacks, so re-imports still skip it
+func MergeDuplicates(ctx context.Context, db *mongo.Database, keepID primitive.ObjectID, ids []primitive.ObjectID) (models.Transaction, []models.Transaction, error) {
+	col := db.Collection("transactions")
+
+	var kept models.Transaction
+	if err := col.FindOne(ctx, utils.NotDeleted(bson.M{"_id": keepID})).Decode(&kept); err != nil {
+		return kept, nil, err
+	}
+	others := []primitive.ObjectID{}
+	for _, id := range ids {
+		if id != keepID {
+			others = append(others, id)
+		}
+	}
+	if len(others) == 0 {
+		return kept, nil, ErrNothingToMerge
+	}
+
+	cursor, err := col.Find(ctx, utils.NotDeleted(bson.M{"_id": bson.M{"$in": others}}))
+	if err != nil {
+		return kept, nil, err
+	}
+	var duplicates []models.Transaction
+	if err := cursor.All(ctx, &duplicates); err != nil {
+		return kept, nil, err
+	}
+	if len(duplicates) != len(others) {
+		return kept, nil, mongo.ErrNoDocuments
+	}
+
+	set := bson.M{}
+	for _, duplicate := range duplicates {
+		if duplicate.Account != kept.Account || duplicate.Amount != kept.Amount || duplicate.Type != kept.Type {
+			return kept, nil, ErrNotDuplicates
+		}
+		if kept.CategoryID.IsZero() && !duplicate.CategoryID.IsZero() {
+			kept.CategoryID = duplicate.CategoryID
+			set["category_id"] = kept.CategoryID
+		}
+		if kept.ExternalID == "" && duplicate.ExternalID != "" {
+			kept.ExternalID = duplicate.ExternalID
+			set["external_id"] = kept.ExternalID
+		}
+		if tags := addTags(kept.Tags, duplicate.Tags); len(tags) != len(kept.Tags) {
+			kept.Tags = tags
+			set["tags"] = kept.Tags
+		}
+	}
+
+	// The external id moves to the kept transaction, so the duplicates go to the trash without it to free the unique index
+	deleted, err := DeleteTransactions(ctx, db, bson.M{"_id": bson.M{"$in": others}})
+	if err != nil {
+		return kept, nil, err
+	}
+	if _, moved := set["external_id"]; moved {
+		if _, err := col.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": others}}, utils.BumpVersion(bson.M{"$unset": bson.M{"external_id": ""}})); err != nil {
+			return kept, nil, err
+		}
+	}
+	if len(set) > 0 {
+		kept.UpdatedAt = time.Now()
+		set["updated_at"] = kept.UpdatedAt
+		if _, err := col.UpdateOne(ctx, bson.M{"_id": keepID}, utils.BumpVersion(bson.M{"$set": set})); err != nil {
+			return kept, nil, err
+		}
+	}
+	return kept, deleted, nil
+}
+
+// DismissDuplicates records that the given transactions aren't duplicates of each other
+func DismissDuplicates(ctx context.Context, db *mongo.Database, ids []primitive.ObjectID) error {
+	if len(ids) < 2 {
+		return errors.New("transaction_ids must hold at least two transactions")
+	}
+	_, err := db.Collection("duplicate_dismissals").InsertOne(ctx, models.DuplicateDismissal{
+		ID:             primitive.NewObjectID(),
+		TransactionIDs: ids,
+		CreatedAt:      time.Now(),
+	})
+	return err
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// averageMonth is used to turn durations into fractional months
+const averageMonth = time.Duration(30.44 * 24 * float64(time.Hour))
+
+// projectionHorizonMonths is how far ahead a completion date is projected; a slower pace gets none
+const projectionHorizonMonths = 100 * 12
+
+func monthsBetween(from, to time.Time) float64 {
+	return float64(to.Sub(from)) / float64(averageMonth)
+}
+
+// goalContributions sums the transactions tagged with the goal. Income and incoming transfers add to it,
+// expenses withdraw from it. It also returns the date of the first contribution
+func goalContributions(ctx context.Context, db *mongo.Database, goal models.Goal) (models.Money, *time.Time, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: utils.NotDeleted(bson.M{"goal_id": goal.ID})}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$type",
+			"total": bson.M{"$sum": "$amount"},
+			"first": bson.M{"$min": "$date"},
+		}}},
+	}
+	cursor, err := db.Collection("transactions").Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		Type  string       `bson:"_id"`
+		Total models.Money `bson:"total"`
+		First time.Time    `bson:"first"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return 0, nil, err
+	}
+
+	var total models.Money
+	var first *time.Time
+	for _, row := range rows {
+		if row.Type == "expense" {
+			total -= row.Total
+		} else {
+			total += row.Total
+		}
+		if first == nil || row.First.Before(*first) {
+			date := row.First
+			first = &date
+		}
+	}
+	return total, first, nil
+}
+
+// ComputeGoalProgress reports the goal progress, the monthly contribution needed to hit the target date
+// and the completion date projected from the pace so far
+func ComputeGoalProgress(ctx context.Context, db *mongo.Database, goal models.Goal, now time.Time) (models.GoalProgress, error) {
+	var current, saved models.Money
+	since := goal.CreatedAt
+
+	if !goal.AccountID.IsZero() {
+		var account models.Account
+		if err := db.Collection("accounts").FindOne(ctx, utils.NotDeleted(bson.M{"_id": goal.AccountID})).Decode(&account); err != nil {
+			return models.GoalProgress{}, err
+		}
+		current = account.Balance
+		saved = account.Balance - goal.InitialAmount
+	} else {
+		total, first, err := goalContributions(ctx, db, goal)
+		if err != nil {
+			return models.GoalProgress{}, err
+		}
+		current, saved = total, total
+		if first != nil && first.Before(since) {
+			since = *first
+		}
+	}
+
+	progress := models.GoalProgress{
+		GoalID:        goal.ID,
+		CurrentAmount: current,
+		TargetAmount:  goal.TargetAmount,
+		Remaining:     max(goal.TargetAmount-current, 0),
+	}
+	progress.PercentComplete = math.Min(math.Round(current.Float64()/goal.TargetAmount.Float64()*10000)/100, 100)
+
+	// Less than a month of history still counts as one month, otherwise a first deposit looks like a huge pace
+	elapsed := math.Max(monthsBetween(since, now), 1)
+	progress.MonthlyPace = models.Money(math.Round(float64(saved) / elapsed))
+
+	progress.MonthsLeft = math.Max(math.Round(monthsBetween(now, goal.TargetDate)*100)/100, 0)
+	if progress.MonthsLeft >= 1 {
+		progress.MonthlyNeeded = models.Money(math.Ceil(float64(progress.Remaining) / progress.MonthsLeft))
+	} else {
+		progress.MonthlyNeeded = progress.Remaining
+	}
+
+	switch {
+	case progress.Remaining == 0:
+		progress.Status = "completed"
+		completed := now
+		progress.ProjectedCompletion = &completed
+	case progress.MonthlyPace > 0:
+		// In months first: as a Duration, a tiny pace overflows int64 and projects into the past
+		months := float64(progress.Remaining) / float64(progress.MonthlyPace)
+		if months > projectionHorizonMonths {
+			progress.Status = "behind"
+			break
+		}
+		whole, fraction := math.Modf(months)
+		projected := now.AddDate(0, int(whole), int(math.Ceil(fraction*averageMonth.Hours()/24)))
+		progress.ProjectedCompletion = &projected
+		if projected.After(goal.TargetDate) {
+			progress.Status = "behind"
+		} else {
+			progress.Status = "on_track"
+		}
+	default:
+		progress.Status = "behind"
+	}
+
+	return progress, nil
+}
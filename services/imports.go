@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrImportAccountNotFound is returned when the account a file is imported into doesn't exist
+var ErrImportAccountNotFound = errors.New("account not found")
+
+// ImportReport lists what happened to every row of an imported file
+type ImportReport struct {
+	DryRun  bool        `json:"dry_run,omitempty"`
+	Created []ImportRow `json:"created"`
+	Skipped []ImportRow `json:"skipped"`
+	Failed  []ImportRow `json:"failed"`
+}
+
+// ImportRow is a row of an imported file. Row is 1-based
+type ImportRow struct {
+	Row           int          `json:"row"`
+	ExternalID    string       `json:"external_id,omitempty"`
+	TransactionID string       `json:"transaction_id,omitempty"`
+	Date          *time.Time   `json:"date,omitempty"`
+	Description   string       `json:"description,omitempty"`
+	Amount        models.Money `json:"amount,omitempty"`
+	Type          string       `json:"type,omitempty"`
+	CategoryID    string       `json:"category_id,omitempty"`
+	Reason        string       `json:"reason,omitempty"`
+}
+
+func newImportReport() ImportReport {
+	return ImportReport{Created: []ImportRow{}, Skipped: []ImportRow{}, Failed: []ImportRow{}}
+}
+
+func importRowFor(row int, transaction models.Transaction) ImportRow {
+	result := ImportRow{
+		Row:         row,
+		ExternalID:  transaction.ExternalID,
+		Description: transaction.Description,
+		Amount:      transaction.Amount,
+		Type:        transaction.Type,
+	}
+	if !transaction.Date.IsZero() {
+		date := transaction.Date
+		result.Date = &date
+	}
+	if !transaction.CategoryID.IsZero() {
+		result.CategoryID = transaction.CategoryID.Hex()
+	}
+	return result
+}
+
+// toUTF8 reads files that aren't valid UTF-8 as Latin-1, the encoding of most Brazilian bank exports
+func toUTF8(data []byte) []byte {
+	if utf8.Valid(data) {
+		return data
+	}
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return []byte(string(runes))
+}
+
+// findImportAccount loads the account a file is imported into
+func findImportAccount(ctx context.Context, db *mongo.Database, accountID primitive.ObjectID) (models.Account, error) {
+	var account models.Account
+	err := db.Collection("accounts").FindOne(ctx, utils.NotDeleted(bson.M{"_id": accountID})).Decode(&account)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return account, ErrImportAccountNotFound
+	}
+	return account, err
+}
+
+// alreadyImported reports whether the account holds a transaction with the same external id.
+// Transactions in the trash count too, so re-importing a file doesn't bring back what was deleted
+func alreadyImported(ctx context.Context, db *mongo.Database, transaction models.Transaction) (bool, error) {
+	if transaction.ExternalID == "" {
+		return false, nil
+	}
+	count, err := db.Collection("transactions").CountDocuments(ctx, bson.M{
+		"account_id":  transaction.Account,
+		"external_id": transaction.ExternalID,
+	})
+	return count > 0, err
+}
+
+// importTransaction inserts an imported transaction, applies it to the balances and audits it.
+// It reports false when the account already holds a transaction with the same external id
+func importTransaction(ctx context.Context, db *mongo.Database, transaction models.Transaction) (bool, error) {
+	if err := AssignStatementPeriod(ctx, db, &transaction); err != nil {
+		return false, err
+	}
+
+	created := false
+	err := utils.RunInTransaction(ctx, db, func(sessCtx mongo.SessionContext) error {
+		created = false
+		imported, err := alreadyImported(sessCtx, db, transaction)
+		if err != nil || imported {
+			return err
+		}
+		if err := InsertTransaction(sessCtx, db, transaction); err != nil {
+			return err
+		}
+		if err := RecordAudit(sessCtx, db, AuditTransaction, AuditCreate, transaction.ID, nil, transaction); err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		// Imported concurrently by another request
+		return false, nil
+	}
+	return created, err
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// installmentSuffix matches the " (k/N)" numbering appended to installment descriptions
+var installmentSuffix = regexp.MustCompile(`\s*\(\d+/\d+\)$`)
+
+// addMonths moves date n months ahead keeping the day, capped to the last day of the target month
+func addMonths(date time.Time, n int) time.Time {
+	firstOfMonth := time.Date(date.Year(), date.Month()+time.Month(n), 1, date.Hour(), date.Minute(), date.Second(), date.Nanosecond(), date.Location())
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	day := date.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return firstOfMonth.AddDate(0, 0, day-1)
+}
+
+func installmentDescription(description string, number, count int) string {
+	base := installmentSuffix.ReplaceAllString(description, "")
+	return fmt.Sprintf("%s (%d/%d)", base, number, count)
+}
+
+// BuildInstallments expands a transaction carrying an InstallmentPlan into its linked monthly installments.
+// The total is split evenly and the leftover cents go to the first installment
+func BuildInstallments(transaction models.Transaction) ([]models.Transaction, error) {
+	plan := transaction.InstallmentPlan
+	if plan == nil {
+		return nil, errors.New("transaction has no installment plan")
+	}
+	if transaction.Type == "transfer" {
+		return nil, errors.New("transfers can't be split into installments")
+	}
+
+	baseAmount := plan.TotalAmount / models.Money(plan.Count)
+	remainder := plan.TotalAmount % models.Money(plan.Count)
+
+	groupID := primitive.NewObjectID()
+	now := time.Now()
+	installments := make([]models.Transaction, 0, plan.Count)
+	for k := 1; k <= plan.Count; k++ {
+		amount := baseAmount
+		if k == 1 {
+			amount += remainder
+		}
+		installment := transaction
+		installment.ID = primitive.NewObjectID()
+		installment.Amount = amount
+		installment.Date = addMonths(plan.FirstDueDate, k-1)
+		installment.Description = installmentDescription(transaction.Description, k, plan.Count)
+		installment.InstallmentGroupID = groupID
+		installment.InstallmentNumber = k
+		installment.InstallmentCount = plan.Count
+		installment.InstallmentPlan = nil
+		installment.StatementPeriod = ""
+		installment.CreatedAt = now
+		installments = append(installments, installment)
+	}
+	return installments, nil
+}
+
+// RemainingInstallmentsFilter selects the given installment and the ones after it in the same group,
+// which is what cascading edits and deletes act on
+func RemainingInstallmentsFilter(transaction models.Transaction) bson.M {
+	return bson.M{
+		"installment_group_id": transaction.InstallmentGroupID,
+		"installment_number":   bson.M{"$gte": transaction.InstallmentNumber},
+	}
+}
+
+// CascadeInstallmentUpdate applies the edited fields of an installment to the ones that come after it.
+// Dates are kept, since every installment lands on its own month. It returns the installments changed
+func CascadeInstallmentUpdate(ctx context.Context, db *mongo.Database, edited models.Transaction) ([]TransactionChange, error) {
+	col := db.Collection("transactions")
+	filter := RemainingInstallmentsFilter(edited)
+	filter["installment_number"] = bson.M{"$gt": edited.InstallmentNumber}
+
+	cursor, err := col.Find(ctx, utils.NotDeleted(filter))
+	if err != nil {
+		return nil, err
+	}
+	var remaining []models.Transaction
+	if err := cursor.All(ctx, &remaining); err != nil {
+		return nil, err
+	}
+
+	changes := make([]TransactionChange, 0, len(remaining))
+	for _, installment := range remaining {
+		installment.Amount = edited.Amount
+		installment.Type = edited.Type
+		installment.CategoryID = edited.CategoryID
+		installment.Account = edited.Account
+		installment.Currency = edited.Currency
+		installment.Description = installmentDescription(edited.Description, installment.InstallmentNumber, installment.InstallmentCount)
+		installment.UpdatedAt = edited.UpdatedAt
+		if err := AssignStatementPeriod(ctx, db, &installment); err != nil {
+			return nil, err
+		}
+
+		set := bson.M{
+			"amount":      installment.Amount,
+			"type":        installment.Type,
+			"description": installment.Description,
+			"currency":    installment.Currency,
+			"updated_at":  installment.UpdatedAt,
+		}
+		unset := bson.M{}
+		setOrUnset(set, unset, "category_id", installment.CategoryID, installment.CategoryID.IsZero())
+		setOrUnset(set, unset, "account_id", installment.Account, installment.Account.IsZero())
+		setOrUnset(set, unset, "statement_period", installment.StatementPeriod, installment.StatementPeriod == "")
+
+		update := bson.M{"$set": set}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+		before, after, err := UpdateTransaction(ctx, db, installment.ID, update)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, TransactionChange{Before: before, After: after})
+	}
+	return changes, nil
+}
+
+func setOrUnset(set, unset bson.M, field string, value interface{}, empty bool) {
+	if empty {
+		unset[field] = ""
+		return
+	}
+	set[field] = value
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The ledger functions write transactions and keep the account balances in step with them.
+// They must run inside utils.RunInTransaction, so the document and the balances change together
+
+// TransactionChange is a transaction before and after a write. Before is empty for inserts and After for deletes
+type TransactionChange struct {
+	Before models.Transaction
+	After  models.Transaction
+}
+
+// ApplyToBalances adds (factor 1) or removes (factor -1) the effect of a transaction on its accounts.
+// Transactions without an account don't move any balance
+func ApplyToBalances(ctx context.Context, db *mongo.Database, transaction models.Transaction, factor models.Money) error {
+	if transaction.Account.IsZero() {
+		return nil
+	}
+	return utils.UpdateAccountBalanceOnTransaction(ctx, db, transaction, factor)
+}
+
+// InsertTransaction inserts a transaction and applies it to the balances
+func InsertTransaction(ctx context.Context, db *mongo.Database, transaction models.Transaction) error {
+	if _, err := db.Collection("transactions").InsertOne(ctx, transaction); err != nil {
+		return err
+	}
+	return ApplyToBalances(ctx, db, transaction, 1)
+}
+
+// ErrStaleVersion is returned when a document changed after the version an update was based on
+var ErrStaleVersion = errors.New("the document changed since it was read, fetch it again")
+
+// UpdateTransaction runs update on a transaction outside the trash, then moves the balances from the old version to the new one.
+// That covers amount, type and account changes alike. It returns both versions
+func UpdateTransaction(ctx context.Context, db *mongo.Database, id primitive.ObjectID, update bson.M) (models.Transaction, models.Transaction, error) {
+	return updateTransaction(ctx, db, id, utils.NotDeleted(bson.M{"_id": id}), update)
+}
+
+// UpdateTransactionAtVersion is UpdateTransaction for a client that read the transaction at version.
+// The version is checked in the update filter itself, so a concurrent write makes it fail with ErrStaleVersion
+func UpdateTransactionAtVersion(ctx context.Context, db *mongo.Database, id primitive.ObjectID, version int64, update bson.M) (models.Transaction, models.Transaction, error) {
+	before, after, err := updateTransaction(ctx, db, id, utils.MatchVersion(utils.NotDeleted(bson.M{"_id": id}), version), update)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if exists, countErr := db.Collection("transactions").CountDocuments(ctx, utils.NotDeleted(bson.M{"_id": id})); countErr == nil && exists > 0 {
+			return before, after, ErrStaleVersion
+		}
+	}
+	return before, after, err
+}
+
+// updateTransaction applies update, with the version increment every write carries, to the transaction matching filter
+func updateTransaction(ctx context.Context, db *mongo.Database, id primitive.ObjectID, filter bson.M, update bson.M) (models.Transaction, models.Transaction, error) {
+	col := db.Collection("transactions")
+
+	var before, after models.Transaction
+	err := col.FindOneAndUpdate(ctx, filter, utils.BumpVersion(update), options.FindOneAndUpdate().SetReturnDocument(options.Before)).Decode(&before)
+	if err != nil {
+		return before, after, err
+	}
+	if err := col.FindOne(ctx, bson.M{"_id": id}).Decode(&after); err != nil {
+		return before, after, err
+	}
+
+	if err := ApplyToBalances(ctx, db, before, -1); err != nil {
+		return before, after, err
+	}
+	if err := ApplyToBalances(ctx, db, after, 1); err != nil {
+		return before, after, err
+	}
+	return before, after, nil
+}
+
+// DeleteTransactions moves every transaction matching filter to the trash and reverts them from the balances.
+// It returns them as they were before the delete
+func DeleteTransactions(ctx context.Context, db *mongo.Database, filter bson.M) ([]models.Transaction, error) {
+	col := db.Collection("transactions")
+
+	cursor, err := col.Find(ctx, utils.NotDeleted(filter))
+	if err != nil {
+		return nil, err
+	}
+	var deleted []models.Transaction
+	if err := cursor.All(ctx, &deleted); err != nil {
+		return nil, err
+	}
+	if len(deleted) == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(deleted))
+	for _, transaction := range deleted {
+		ids = append(ids, transaction.ID)
+	}
+	if _, err := col.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": ids}}, utils.BumpVersion(bson.M{"$set": bson.M{"deleted_at": time.Now()}})); err != nil {
+		return nil, err
+	}
+
+	for _, transaction := range deleted {
+		if err := ApplyToBalances(ctx, db, transaction, -1); err != nil {
+			return nil, err
+		}
+	}
+	return deleted, nil
+}
+
+// IsNotFound reports whether a ledger error means the transaction doesn't exist
+func IsNotFound(err error) bool {
+	return errors.Is(err, mongo.ErrNoDocuments)
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// migration is a one-time data change. Every migration must be safe to run again if it stops halfway
+type migration struct {
+	ID  string
+	Run func(ctx context.Context, db *mongo.Database, cfg *config.Config) error
+}
+
+var migrations = []migration{
+	{ID: "0001_money_to_minor_units", Run: migrateMoneyToMinorUnits},
+	{ID: "0002_default_currency", Run: migrateDefaultCurrency},
+}
+
+// RunMigrations applies the migrations not yet recorded in the migrations collection, in order
+func RunMigrations(db *mongo.Database, cfg *config.Config, ctx context.Context) error {
+	col := db.Collection("migrations")
+	for _, m := range migrations {
+		count, err := col.CountDocuments(ctx, bson.M{"_id": m.ID})
+		if err != nil {
+			return fmt.Errorf("failed to check migration %s: %w", m.ID, err)
+		}
+		if count > 0 {
+			continue
+		}
+
+		log.Printf("Running migration %s...", m.ID)
+		if err := m.Run(ctx, db, cfg); err != nil {
+			return fmt.Errorf("migration %s failed: %w", m.ID, err)
+		}
+		if _, err := col.InsertOne(ctx, bson.M{"_id": m.ID, "applied_at": time.Now()}); err != nil {
+			return fmt.Errorf("failed to record migration %s: %w", m.ID, err)
+		}
+		log.Printf("Migration %s applied", m.ID)
+	}
+	return nil
+}
+
+// migrateMoneyToMinorUnits converts money stored as floating point units into int64 cents.
+// The value goes through Decimal128 before the multiplication, so 0.29 becomes 29 and not 28.999...
+// Values already stored as long are left alone, which makes the migration resumable
+func migrateMoneyToMinorUnits(ctx context.Context, db *mongo.Database, _ *config.Config) error {
+	fields := map[string][]string{
+		"transactions": {"amount"},
+		"accounts":     {"balance"},
+		"recurrences":  {"template.amount"},
+		"budgets":      {"amount"},
+		"goals":        {"target_amount", "initial_amount"},
+	}
+
+	for collection, names := range fields {
+		for _, field := range names {
+			filter := bson.M{field: bson.M{"$type": bson.A{"double", "int", "decimal"}}}
+			toCents := bson.M{"$toLong": bson.M{"$round": bson.A{
+				bson.M{"$multiply": bson.A{bson.M{"$toDecimal": "$" + field}, 100}},
+				0,
+			}}}
+			update := mongo.Pipeline{{{Key: "$set", Value: bson.M{field: toCents}}}}
+
+			result, err := db.Collection(collection).UpdateMany(ctx, filter, update)
+			if err != nil {
+				return fmt.Errorf("failed to convert %s.%s: %w", collection, field, err)
+			}
+			log.Printf("Converted %d documents in %s.%s to cents", result.ModifiedCount, collection, field)
+		}
+	}
+	return nil
+}
+
+// migrateDefaultCurrency puts every account created before currencies existed in the base currency,
+// then copies the account currency into its transactions
+func migrateDefaultCurrency(ctx context.Context, db *mongo.Database, cfg *config.Config) error {
+	missing := bson.M{"$or": bson.A{bson.M{"currency": bson.M{"$exists": false}}, bson.M{"currency": ""}}}
+
+	if _, err := db.Collection("accounts").UpdateMany(ctx, missing, bson.M{"$set": bson.M{"currency": cfg.Currency.Base}}); err != nil {
+		return fmt.Errorf("failed to set account currencies: %w", err)
+	}
+
+	// Transactions take the currency of their account
+	cursor, err := db.Collection("accounts").Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	var accounts []struct {
+		ID       primitive.ObjectID `bson:"_id"`
+		Currency string             `bson:"currency"`
+	}
+	if err := cursor.All(ctx, &accounts); err != nil {
+		return err
+	}
+	for _, account := range accounts {
+		filter := bson.M{"$and": bson.A{missing, bson.M{"account_id": account.ID}}}
+		if _, err := db.Collection("transactions").UpdateMany(ctx, filter, bson.M{"$set": bson.M{"currency": account.Currency}}); err != nil {
+			return fmt.Errorf("failed to set transaction currencies: %w", err)
+		}
+	}
+
+	// Whatever is left has no account
+	_, err = db.Collection("transactions").UpdateMany(ctx, missing, bson.M{"$set": bson.M{"currency": cfg.Currency.Base}})
+	return err
+}
+
+// SchemaVersion is the version of the stored data, the number of migrations it went through
+func SchemaVersion() int {
+	return len(migrations)
+}
+
+// UpgradeRestoredData runs every migration after fromVersion over the database again, so documents
+// restored from an older backup reach the current schema. Migrations skip documents already migrated
+func UpgradeRestoredData(ctx context.Context, db *mongo.Database, cfg *config.Config, fromVersion int) error {
+	for _, m := range migrations[fromVersion:] {
+		log.Printf("Upgrading restored data with migration %s...", m.ID)
+		if err := m.Run(ctx, db, cfg); err != nil {
+			return fmt.Errorf("migration %s failed: %w", m.ID, err)
+		}
+	}
+	return nil
+}
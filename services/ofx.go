@@ -0,0 +1,219 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/config"
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ofxTagPattern matches an opening or closing tag and the text right after it.
+// OFX 1.x (SGML) leaves leaf elements unclosed, so the text up to the next tag is the value in both versions
+var ofxTagPattern = regexp.MustCompile(`<(/?)([A-Za-z0-9.]+)[^>]*>([^<]*)`)
+
+// ofxTransaction is a STMTTRN entry with its raw values
+type ofxTransaction struct {
+	FitID  string
+	Posted string
+	Amount string
+	Name   string
+	Memo   string
+}
+
+// ofxStatement holds what the import needs from an OFX file
+type ofxStatement struct {
+	Currency     string
+	Transactions []ofxTransaction
+}
+
+// parseOFX reads the STMTTRN entries of an OFX 1.x or 2.x file, from bank or credit card statements
+func parseOFX(file io.Reader) (ofxStatement, error) {
+	var statement ofxStatement
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return statement, err
+	}
+	// OFX 1.x files from Brazilian banks are usually Windows-1252
+	data = toUTF8(data)
+
+	start := bytes.Index(bytes.ToUpper(data), []byte("<OFX>"))
+	if start < 0 {
+		return statement, errors.New("not an OFX file: <OFX> element not found")
+	}
+
+	var current *ofxTransaction
+	flush := func() {
+		if current != nil {
+			statement.Transactions = append(statement.Transactions, *current)
+			current = nil
+		}
+	}
+
+	for _, match := range ofxTagPattern.FindAllStringSubmatch(string(data[start:]), -1) {
+		closing := match[1] == "/"
+		tag := strings.ToUpper(match[2])
+		value := strings.TrimSpace(html.UnescapeString(match[3]))
+
+		switch {
+		case tag == "STMTTRN":
+			flush()
+			if !closing {
+				current = &ofxTransaction{}
+			}
+		case tag == "BANKTRANLIST" && closing:
+			flush()
+		case closing || value == "":
+		case tag == "CURDEF":
+			statement.Currency = strings.ToUpper(value)
+		case current == nil:
+		case tag == "FITID":
+			current.FitID = value
+		case tag == "DTPOSTED":
+			current.Posted = value
+		case tag == "TRNAMT":
+			current.Amount = value
+		case tag == "NAME":
+			current.Name = value
+		case tag == "MEMO":
+			current.Memo = value
+		}
+	}
+	flush()
+
+	return statement, nil
+}
+
+// parseOFXDate reads the day of an OFX datetime (YYYYMMDD[HHMMSS[.XXX][[-3:BRT]]])
+func parseOFXDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("invalid date '%s'", value)
+	}
+	date, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date '%s'", value)
+	}
+	return date, nil
+}
+
+// parseOFXAmount reads a signed TRNAMT, accepting a decimal comma
+func parseOFXAmount(value string) (models.Money, error) {
+	if strings.Contains(value, ",") && !strings.Contains(value, ".") {
+		value = strings.Replace(value, ",", ".", 1)
+	}
+	return models.ParseMoney(value)
+}
+
+// transactionFromOFX maps a STMTTRN entry to a transaction: the sign gives the type and the memo the description
+func transactionFromOFX(entry ofxTransaction, account models.Account, currency string) (models.Transaction, error) {
+	transaction := models.Transaction{
+		Account:     account.ID,
+		Currency:    currency,
+		ExternalID:  entry.FitID,
+		Description: entry.Memo,
+	}
+	if transaction.Description == "" {
+		transaction.Description = entry.Name
+	}
+
+	date, err := parseOFXDate(entry.Posted)
+	if err != nil {
+		return transaction, err
+	}
+	transaction.Date = date
+
+	amount, err := parseOFXAmount(entry.Amount)
+	if err != nil {
+		return transaction, err
+	}
+	switch {
+	case amount > 0:
+		transaction.Type = "income"
+		transaction.Amount = amount
+	case amount < 0:
+		transaction.Type = "expense"
+		transaction.Amount = -amount
+	default:
+		return transaction, errors.New("zero amount")
+	}
+
+	if entry.FitID == "" {
+		return transaction, errors.New("missing FITID")
+	}
+	return transaction, nil
+}
+
+// ImportOFX creates a transaction in accountID for every STMTTRN entry of an OFX file.
+// Entries whose FITID was already imported into the account are skipped
+func ImportOFX(ctx context.Context, db *mongo.Database, accountID primitive.ObjectID, file io.Reader, cfg *config.Config) (ImportReport, error) {
+	report := newImportReport()
+
+	account, err := findImportAccount(ctx, db, accountID)
+	if err != nil {
+		return report, err
+	}
+	currency := account.Currency
+	if currency == "" {
+		currency = cfg.Currency.Base
+	}
+
+	statement, err := parseOFX(file)
+	if err != nil {
+		return report, err
+	}
+	if statement.Currency != "" && statement.Currency != currency {
+		return report, fmt.Errorf("statement currency %s doesn't match the account currency %s", statement.Currency, currency)
+	}
+
+	rules, err := LoadRuleSet(ctx, db)
+	if err != nil {
+		return report, err
+	}
+	categorizer, err := LoadCategorizer(ctx, db, cfg.Classifier.AutoApplyThreshold)
+	if err != nil {
+		return report, err
+	}
+
+	now := time.Now()
+	for i, entry := range statement.Transactions {
+		row := i + 1
+		transaction, err := transactionFromOFX(entry, account, currency)
+		if err != nil {
+			failed := importRowFor(row, transaction)
+			failed.Reason = err.Error()
+			report.Failed = append(report.Failed, failed)
+			continue
+		}
+		rules.Apply(&transaction, false)
+		if err := categorizer.Apply(ctx, &transaction); err != nil {
+			return report, err
+		}
+		transaction.ID = primitive.NewObjectID()
+		transaction.CreatedAt = now
+
+		created, err := importTransaction(ctx, db, transaction)
+		result := importRowFor(row, transaction)
+		switch {
+		case err != nil:
+			result.Reason = err.Error()
+			report.Failed = append(report.Failed, result)
+		case !created:
+			result.Reason = "already imported"
+			report.Skipped = append(report.Skipped, result)
+		default:
+			result.TransactionID = transaction.ID.Hex()
+			report.Created = append(report.Created, result)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxOccurrenceScan bounds how many schedule steps are walked from the window start, so a window of years can't loop forever
+const maxOccurrenceScan = 10000
+
+// occurrenceAt returns the n-th (0 based) candidate date of a schedule
+func occurrenceAt(schedule models.Schedule, n int) time.Time {
+	interval := schedule.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	start := schedule.StartDate
+
+	switch schedule.Frequency {
+	case "daily":
+		return start.AddDate(0, 0, n*interval)
+	case "weekly":
+		return start.AddDate(0, 0, 7*n*interval)
+	case "yearly":
+		return addMonths(start, 12*n*interval)
+	default: // monthly
+		if schedule.DayOfMonth == 0 {
+			return addMonths(start, n*interval)
+		}
+		month := time.Date(start.Year(), start.Month(), 1, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+		month = addMonths(month, n*interval)
+		day := min(schedule.DayOfMonth, month.AddDate(0, 1, -1).Day())
+		return month.AddDate(0, 0, day-1)
+	}
+}
+
+// firstStepFrom returns the step of the first candidate date at or after from, so a schedule started
+// long ago isn't walked from its start. The elapsed time gives an estimate that is then corrected
+func firstStepFrom(schedule models.Schedule, from time.Time) int {
+	start := schedule.StartDate
+	if !from.After(start) {
+		return 0
+	}
+	interval := schedule.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	months := (from.Year()-start.Year())*12 + int(from.Month()) - int(start.Month())
+	var n int
+	switch schedule.Frequency {
+	case "daily":
+		n = int(from.Sub(start).Hours()/24) / interval
+	case "weekly":
+		n = int(from.Sub(start).Hours()/(24*7)) / interval
+	case "yearly":
+		n = months / (12 * interval)
+	default: // monthly
+		n = months / interval
+	}
+	// Daylight saving and month lengths can put the estimate a step off either way
+	n = max(0, n-2)
+	for n > 0 && !occurrenceAt(schedule, n-1).Before(from) {
+		n--
+	}
+	for occurrenceAt(schedule, n).Before(from) {
+		n++
+	}
+	return n
+}
+
+// Occurrences lists the schedule dates in [from, to], at most limit of them (0 means no limit)
+func Occurrences(schedule models.Schedule, from, to time.Time, limit int) []time.Time {
+	occurrences := []time.Time{}
+	first := firstStepFrom(schedule, from)
+	// Every skipped step was an occurrence, but the first when its day of month falls before the start
+	counted := first
+	if first > 0 && occurrenceAt(schedule, 0).Before(schedule.StartDate) {
+		counted--
+	}
+	for n := first; n < first+maxOccurrenceScan; n++ {
+		date := occurrenceAt(schedule, n)
+		// A day of month earlier than the start day skips the first month
+		if date.Before(schedule.StartDate) {
+			continue
+		}
+		if schedule.EndDate != nil && date.After(*schedule.EndDate) {
+			break
+		}
+		if schedule.Count > 0 && counted >= schedule.Count {
+			break
+		}
+		counted++
+		if date.After(to) {
+			break
+		}
+		if date.Before(from) {
+			continue
+		}
+		occurrences = append(occurrences, date)
+		if limit > 0 && len(occurrences) >= limit {
+			break
+		}
+	}
+	return occurrences
+}
+
+// TransactionFromRecurrence builds the transaction standing for one occurrence of a recurrence
+func TransactionFromRecurrence(recurrence models.Recurrence, date time.Time) models.Transaction {
+	occurrence := date
+	return models.Transaction{
+		Amount:             recurrence.Template.Amount,
+		Date:               date,
+		Description:        recurrence.Template.Description,
+		CategoryID:         recurrence.Template.CategoryID,
+		Type:               recurrence.Template.Type,
+		Account:            recurrence.Template.Account,
+		DestinationAccount: recurrence.Template.DestinationAccount,
+		DestinationAmount:  recurrence.Template.DestinationAmount,
+		RecurrenceID:       recurrence.ID,
+		OccurrenceDate:     &occurrence,
+	}
+}
+
+// MaterializeRecurrence creates the transactions of every occurrence due up to now.
+// Inserts are upserts keyed by (recurrence_id, occurrence_date), so running it twice never duplicates
+func MaterializeRecurrence(ctx context.Context, db *mongo.Database, recurrence models.Recurrence, now time.Time, baseCurrency string) (int, error) {
+	from := recurrence.Schedule.StartDate
+	if recurrence.LastOccurrence != nil {
+		from = recurrence.LastOccurrence.Add(time.Nanosecond)
+	}
+	dates := Occurrences(recurrence.Schedule, from, now, 0)
+	if len(dates) == 0 {
+		return 0, nil
+	}
+
+	transactionsCol := db.Collection("transactions")
+	created := 0
+	for _, date := range dates {
+		transaction := TransactionFromRecurrence(recurrence, date)
+		if err := utils.ValidateTransaction(transaction); err != nil {
+			return created, err
+		}
+
+		inserted := false
+		err := utils.RunInTransaction(ctx, db, func(sessCtx mongo.SessionContext) error {
+			inserted = false
+			if err := ApplyAccountCurrency(sessCtx, db, &transaction, baseCurrency); err != nil {
+				return err
+			}
+			if err := AssignStatementPeriod(sessCtx, db, &transaction); err != nil {
+				return err
+			}
+			transaction.CreatedAt = time.Now()
+
+			filter := bson.M{"recurrence_id": recurrence.ID, "occurrence_date": date}
+			result, err := transactionsCol.UpdateOne(sessCtx, filter, bson.M{"$setOnInsert": transaction}, options.Update().SetUpsert(true))
+			if err != nil {
+				return err
+			}
+			if result.UpsertedCount == 0 {
+				return nil
+			}
+			inserted = true
+			return ApplyToBalances(sessCtx, db, transaction, 1)
+		})
+		if err != nil {
+			return created, err
+		}
+		if inserted {
+			created++
+		}
+	}
+
+	last := dates[len(dates)-1]
+	_, err := db.Collection("recurrences").UpdateOne(ctx, bson.M{"_id": recurrence.ID}, bson.M{"$set": bson.M{"last_occurrence": last}})
+	return created, err
+}
+
+// MaterializeRecurrencesService runs MaterializeRecurrence for every active recurrence
+func MaterializeRecurrencesService(db *mongo.Database, ctx context.Context, baseCurrency string) (int, error) {
+	log.Println("Starting scheduled task: materializing recurring transactions...")
+
+	cursor, err := db.Collection("recurrences").Find(ctx, bson.M{"paused": bson.M{"$ne": true}})
+	if err != nil {
+		log.Printf("ERROR in scheduler: failed to fetch recurrences: %v", err)
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var recurrences []models.Recurrence
+	if err := cursor.All(ctx, &recurrences); err != nil {
+		log.Printf("ERROR in scheduler: failed to decode recurrences: %v", err)
+		return 0, err
+	}
+
+	now := time.Now()
+	created := 0
+	var errs []error
+	for _, recurrence := range recurrences {
+		n, err := MaterializeRecurrence(ctx, db, recurrence, now, baseCurrency)
+		created += n
+		if err != nil {
+			log.Printf("ERROR in scheduler: failed to materialize recurrence %s: %v", recurrence.ID.Hex(), err)
+			errs = append(errs, err)
+		}
+	}
+
+	log.Printf("Task finished: %d recurring transactions created.", created)
+	return created, errors.Join(errs...)
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/models"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestOccurrences(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule models.Schedule
+		from, to time.Time
+		want     []time.Time
+	}{
+		{
+			name:     "day_of_month 31 clamps to the end of shorter months",
+			schedule: models.Schedule{Frequency: "monthly", DayOfMonth: 31, StartDate: day(2025, time.January, 31)},
+			from:     day(2025, time.January, 1),
+			to:       day(2025, time.May, 1),
+			want:     []time.Time{day(2025, time.January, 31), day(2025, time.February, 28), day(2025, time.March, 31), day(2025, time.April, 30)},
+		},
+		{
+			name:     "day_of_month 31 clamps to February 29 in a leap year",
+			schedule: models.Schedule{Frequency: "monthly", DayOfMonth: 31, StartDate: day(2024, time.January, 15)},
+			from:     day(2024, time.February, 1),
+			to:       day(2024, time.March, 31),
+			want:     []time.Time{day(2024, time.February, 29), day(2024, time.March, 31)},
+		},
+		{
+			name:     "a start day of month after day_of_month skips the first month",
+			schedule: models.Schedule{Frequency: "monthly", DayOfMonth: 5, StartDate: day(2025, time.January, 20)},
+			from:     day(2025, time.January, 1),
+			to:       day(2025, time.March, 31),
+			want:     []time.Time{day(2025, time.February, 5), day(2025, time.March, 5)},
+		},
+		{
+			name:     "count is honored after the steps before the window are skipped",
+			schedule: models.Schedule{Frequency: "daily", Count: 10, StartDate: day(2025, time.January, 1)},
+			from:     day(2025, time.January, 8),
+			to:       day(2025, time.January, 31),
+			want:     []time.Time{day(2025, time.January, 8), day(2025, time.January, 9), day(2025, time.January, 10)},
+		},
+		{
+			name:     "count doesn't include a skipped first month",
+			schedule: models.Schedule{Frequency: "monthly", DayOfMonth: 5, Count: 3, StartDate: day(2025, time.January, 20)},
+			from:     day(2025, time.March, 1),
+			to:       day(2025, time.December, 31),
+			want:     []time.Time{day(2025, time.March, 5), day(2025, time.April, 5)},
+		},
+		{
+			name:     "count already reached before the window",
+			schedule: models.Schedule{Frequency: "weekly", Count: 4, StartDate: day(2025, time.January, 1)},
+			from:     day(2025, time.February, 1),
+			to:       day(2025, time.March, 1),
+			want:     []time.Time{},
+		},
+		{
+			name:     "a daily schedule started more steps ago than the scan cap",
+			schedule: models.Schedule{Frequency: "daily", StartDate: day(1990, time.January, 1)},
+			from:     day(2025, time.June, 1),
+			to:       day(2025, time.June, 3),
+			want:     []time.Time{day(2025, time.June, 1), day(2025, time.June, 2), day(2025, time.June, 3)},
+		},
+		{
+			name:     "interval and end date",
+			schedule: models.Schedule{Frequency: "yearly", Interval: 2, StartDate: day(2020, time.February, 29), EndDate: ptr(day(2026, time.December, 31))},
+			from:     day(2021, time.January, 1),
+			to:       day(2030, time.December, 31),
+			want:     []time.Time{day(2022, time.February, 28), day(2024, time.February, 29), day(2026, time.February, 28)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Occurrences(tt.schedule, tt.from, tt.to, 0)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFirstStepFromAcrossDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+	local := func(month time.Month, d, hour int) time.Time {
+		return time.Date(2025, month, d, hour, 30, 0, 0, newYork)
+	}
+
+	// Clocks went forward on March 9 and back on November 2, 2025, so the elapsed hours are one off a whole day
+	tests := []struct {
+		name     string
+		schedule models.Schedule
+		from     time.Time
+		want     int
+	}{
+		{"daily, spring forward, from on an occurrence", models.Schedule{Frequency: "daily", StartDate: local(time.March, 1, 0)}, local(time.March, 20, 0), 19},
+		{"daily, spring forward, from just after an occurrence", models.Schedule{Frequency: "daily", StartDate: local(time.March, 1, 0)}, local(time.March, 20, 1), 20},
+		{"daily, fall back, from on an occurrence", models.Schedule{Frequency: "daily", StartDate: local(time.October, 25, 0)}, local(time.November, 10, 0), 16},
+		{"weekly, spring forward", models.Schedule{Frequency: "weekly", StartDate: local(time.March, 3, 0)}, local(time.March, 17, 0), 2},
+		{"weekly, fall back, from the day before an occurrence", models.Schedule{Frequency: "weekly", StartDate: local(time.October, 27, 0)}, local(time.November, 9, 0), 2},
+		{"from before the start", models.Schedule{Frequency: "daily", StartDate: local(time.March, 1, 0)}, local(time.February, 1, 0), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := firstStepFrom(tt.schedule, tt.from)
+			if got != tt.want {
+				t.Fatalf("got step %d (%v), want %d (%v)", got, occurrenceAt(tt.schedule, got), tt.want, occurrenceAt(tt.schedule, tt.want))
+			}
+			if got > 0 && !occurrenceAt(tt.schedule, got-1).Before(tt.from) {
+				t.Fatalf("step %d is at or after from, the first one was skipped", got-1)
+			}
+		})
+	}
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,621 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrReferenceInUse        = errors.New("still in use; reassign or cascade the references to delete it")
+	ErrReassignTargetMissing = errors.New("reassign target not found")
+	ErrReassignTargetInvalid = errors.New("reassign target must be a different document of the same currency or type")
+	ErrReassignSelfTransfer  = errors.New("transfers between the deleted account and the target can't be reassigned")
+)
+
+// Delete modes: by default a referenced document isn't deleted
+const (
+	DeleteRefuse   = ""
+	DeleteReassign = "reassign"
+	DeleteCascade  = "cascade"
+)
+
+// ReferenceUsage counts the documents pointing at an account or category
+type ReferenceUsage struct {
+	Transactions  int64 `json:"transactions"`
+	Recurrences   int64 `json:"recurrences"`
+	Subcategories int64 `json:"subcategories,omitempty"`
+	Rules         int64 `json:"rules,omitempty"` // Rules with a condition on the account
+}
+
+// InUse reports whether anything points at the document
+func (u ReferenceUsage) InUse() bool {
+	return u.Transactions > 0 || u.Recurrences > 0 || u.Subcategories > 0 || u.Rules > 0
+}
+
+// reference is a field holding the id of another document. Settings that only steer future
+// transactions (rule actions, import profiles, goals) follow a reassignment or lose the reference
+// while the document is in the trash.
+// A rule condition on an account can't just be dropped, that would widen the rule to every account,
+// so those rules count as usage and are disabled by a cascade instead
+type reference struct {
+	collection string
+	field      string
+}
+
+// accountRuleField is the rule condition on an account
+const accountRuleField = "conditions.account_id"
+
+var accountSettingRefs = []reference{
+	{"import_profiles", "account_id"},
+	{"goals", "account_id"},
+}
+
+var categorySettingRefs = []reference{
+	{"rules", "actions.category_id"},
+	{"import_profiles", "category_id"},
+}
+
+// moveReferences points refs from one id to another
+func moveReferences(ctx context.Context, db *mongo.Database, refs []reference, from, to primitive.ObjectID) error {
+	for _, ref := range refs {
+		if _, err := db.Collection(ref.collection).UpdateMany(ctx, bson.M{ref.field: from}, bson.M{"$set": bson.M{ref.field: to}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// How a delete took a reference away from another document
+const (
+	detachUnset   = "unset"   // Field removed
+	detachPull    = "pull"    // Id pulled from the Field list
+	detachDisable = "disable" // Rule disabled, its condition Field kept
+	detachLift    = "lift"    // Subcategory moved from the deleted category to Replaced, its grandparent
+)
+
+// detachedReference is a reference a delete took away from another document. The list is kept on the
+// document in the trash, so a restore puts the references back; a purge just drops it
+type detachedReference struct {
+	Collection string             `bson:"collection"`
+	ID         primitive.ObjectID `bson:"id"` // Document that held the reference
+	Field      string             `bson:"field"`
+	Action     string             `bson:"action"`
+	Replaced   primitive.ObjectID `bson:"replaced,omitempty"`
+}
+
+// referencingIDs returns the ids of the documents of collection matching filter
+func referencingIDs(ctx context.Context, db *mongo.Database, collection string, filter bson.M) ([]primitive.ObjectID, error) {
+	cursor, err := db.Collection(collection).Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var documents []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(documents))
+	for _, document := range documents {
+		ids = append(ids, document.ID)
+	}
+	return ids, nil
+}
+
+// detach applies update to the documents of collection where filter matches and records them as detached
+func detach(ctx context.Context, db *mongo.Database, collection, field, action string, filter, update bson.M) ([]detachedReference, error) {
+	ids, err := referencingIDs(ctx, db, collection, filter)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	if _, err := db.Collection(collection).UpdateMany(ctx, bson.M{"_id": bson.M{"$in": ids}}, update); err != nil {
+		return nil, err
+	}
+	detached := make([]detachedReference, 0, len(ids))
+	for _, docID := range ids {
+		detached = append(detached, detachedReference{Collection: collection, ID: docID, Field: field, Action: action})
+	}
+	return detached, nil
+}
+
+// detachReferences removes refs pointing at id
+func detachReferences(ctx context.Context, db *mongo.Database, refs []reference, id primitive.ObjectID) ([]detachedReference, error) {
+	all := []detachedReference{}
+	for _, ref := range refs {
+		detached, err := detach(ctx, db, ref.collection, ref.field, detachUnset, bson.M{ref.field: id}, bson.M{"$unset": bson.M{ref.field: ""}})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, detached...)
+	}
+	return all, nil
+}
+
+// moveToTrash marks a deleted account or category, keeping what the delete detached for a restore
+func moveToTrash(ctx context.Context, col *mongo.Collection, id primitive.ObjectID, detached []detachedReference) error {
+	set := bson.M{"deleted_at": time.Now()}
+	if len(detached) > 0 {
+		set["detached"] = detached
+	}
+	_, err := col.UpdateOne(ctx, bson.M{"_id": id}, utils.BumpVersion(bson.M{"$set": set}))
+	return err
+}
+
+// reattachReferences puts back the references a delete took away from other documents, unless they
+// changed since: a field set again, a rule re-enabled or a subcategory moved elsewhere stay as they are.
+// It must run inside utils.RunInTransaction
+func reattachReferences(ctx context.Context, db *mongo.Database, id primitive.ObjectID, detached []detachedReference) error {
+	for _, ref := range detached {
+		filter := bson.M{"_id": ref.ID}
+		var update bson.M
+		switch ref.Action {
+		case detachUnset:
+			filter[ref.Field] = bson.M{"$exists": false}
+			update = bson.M{"$set": bson.M{ref.Field: id}}
+		case detachPull:
+			update = bson.M{"$addToSet": bson.M{ref.Field: id}}
+		case detachDisable:
+			filter[ref.Field] = id
+			filter["disabled"] = true
+			update = bson.M{"$set": bson.M{"disabled": false, "updated_at": time.Now()}}
+		case detachLift:
+			if err := reattachSubcategory(ctx, db, id, ref); err != nil {
+				return err
+			}
+			continue
+		default:
+			continue
+		}
+		if _, err := db.Collection(ref.Collection).UpdateOne(ctx, filter, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reattachSubcategory moves a lifted subcategory back under the restored category, if it is still where
+// the delete put it and moving it back doesn't break the tree
+func reattachSubcategory(ctx context.Context, db *mongo.Database, parentID primitive.ObjectID, ref detachedReference) error {
+	col := db.Collection("categories")
+
+	filter := utils.NotDeleted(bson.M{"_id": ref.ID, "parent_id": ref.Replaced})
+	if ref.Replaced.IsZero() {
+		filter["parent_id"] = bson.M{"$exists": false}
+	}
+	var child models.Category
+	if err := col.FindOne(ctx, filter).Decode(&child); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+		return err
+	}
+	moved := child
+	moved.ParentID = parentID
+	if err := ValidateCategoryHierarchy(ctx, db, moved); err != nil {
+		if IsCategoryHierarchyError(err) {
+			return nil
+		}
+		return err
+	}
+
+	var after models.Category
+	update := utils.BumpVersion(bson.M{"$set": bson.M{"parent_id": parentID, "updated_at": time.Now()}})
+	if err := col.FindOneAndUpdate(ctx, bson.M{"_id": child.ID}, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&after); err != nil {
+		return err
+	}
+	return RecordAudit(ctx, db, AuditCategory, AuditUpdate, child.ID, child, after)
+}
+
+// RestoreReferences puts back what the delete of an account or category detached, then forgets the list.
+// It must run inside utils.RunInTransaction, after the document left the trash
+func RestoreReferences(ctx context.Context, db *mongo.Database, collection string, id primitive.ObjectID) error {
+	col := db.Collection(collection)
+	var trashed struct {
+		Detached []detachedReference `bson:"detached"`
+	}
+	if err := col.FindOne(ctx, bson.M{"_id": id}).Decode(&trashed); err != nil {
+		return err
+	}
+	if len(trashed.Detached) == 0 {
+		return nil
+	}
+	if err := reattachReferences(ctx, db, id, trashed.Detached); err != nil {
+		return err
+	}
+	_, err := col.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$unset": bson.M{"detached": ""}})
+	return err
+}
+
+func accountTransactionsFilter(id primitive.ObjectID) bson.M {
+	return bson.M{"$or": bson.A{bson.M{"account_id": id}, bson.M{"destination_account_id": id}}}
+}
+
+func accountRecurrencesFilter(id primitive.ObjectID) bson.M {
+	return bson.M{"$or": bson.A{bson.M{"template.account_id": id}, bson.M{"template.destination_account_id": id}}}
+}
+
+func categoryTransactionsFilter(id primitive.ObjectID) bson.M {
+	return bson.M{"$or": bson.A{bson.M{"category_id": id}, bson.M{"splits.category_id": id}}}
+}
+
+// AccountUsage counts the transactions, recurrences and enabled rules using an account
+func AccountUsage(ctx context.Context, db *mongo.Database, id primitive.ObjectID) (ReferenceUsage, error) {
+	var usage ReferenceUsage
+	var err error
+	if usage.Transactions, err = db.Collection("transactions").CountDocuments(ctx, utils.NotDeleted(accountTransactionsFilter(id))); err != nil {
+		return usage, err
+	}
+	if usage.Recurrences, err = db.Collection("recurrences").CountDocuments(ctx, accountRecurrencesFilter(id)); err != nil {
+		return usage, err
+	}
+	usage.Rules, err = db.Collection("rules").CountDocuments(ctx, bson.M{accountRuleField: id, "disabled": bson.M{"$ne": true}})
+	return usage, err
+}
+
+// CategoryUsage counts the transactions, splits included, recurrences and subcategories using a category
+func CategoryUsage(ctx context.Context, db *mongo.Database, id primitive.ObjectID) (ReferenceUsage, error) {
+	var usage ReferenceUsage
+	var err error
+	if usage.Transactions, err = db.Collection("transactions").CountDocuments(ctx, utils.NotDeleted(categoryTransactionsFilter(id))); err != nil {
+		return usage, err
+	}
+	if usage.Recurrences, err = db.Collection("recurrences").CountDocuments(ctx, bson.M{"template.category_id": id}); err != nil {
+		return usage, err
+	}
+	usage.Subcategories, err = db.Collection("categories").CountDocuments(ctx, utils.NotDeleted(bson.M{"parent_id": id}))
+	return usage, err
+}
+
+// DeleteAccount moves an account to the trash with everything that uses it handled by mode: refused while in use,
+// moved to target (same currency, balances follow the transactions) or deleted along with it, the rules
+// conditioned on it being disabled rather than deleted.
+// Every change is written to the audit log. It returns the usage found and must run inside utils.RunInTransaction
+func DeleteAccount(ctx context.Context, db *mongo.Database, id primitive.ObjectID, mode string, target primitive.ObjectID, baseCurrency string) (ReferenceUsage, error) {
+	accountsCol := db.Collection("accounts")
+
+	var account models.Account
+	if err := accountsCol.FindOne(ctx, utils.NotDeleted(bson.M{"_id": id})).Decode(&account); err != nil {
+		return ReferenceUsage{}, err
+	}
+	usage, err := AccountUsage(ctx, db, id)
+	if err != nil {
+		return usage, err
+	}
+
+	var detached []detachedReference
+	switch mode {
+	case DeleteRefuse:
+		if usage.InUse() {
+			return usage, ErrReferenceInUse
+		}
+		if detached, err = detachReferences(ctx, db, accountSettingRefs, id); err != nil {
+			return usage, err
+		}
+
+	case DeleteReassign:
+		var destination models.Account
+		if err := accountsCol.FindOne(ctx, utils.NotDeleted(bson.M{"_id": target})).Decode(&destination); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return usage, ErrReassignTargetMissing
+			}
+			return usage, err
+		}
+		if target == id || currencyOf(account, baseCurrency) != currencyOf(destination, baseCurrency) {
+			return usage, ErrReassignTargetInvalid
+		}
+		if err := reassignAccountTransactions(ctx, db, id, target); err != nil {
+			return usage, err
+		}
+		for _, field := range []string{"template.account_id", "template.destination_account_id"} {
+			if _, err := db.Collection("recurrences").UpdateMany(ctx, bson.M{field: id}, bson.M{"$set": bson.M{field: target}}); err != nil {
+				return usage, err
+			}
+		}
+		if _, err := db.Collection("rules").UpdateMany(ctx, bson.M{accountRuleField: id}, bson.M{"$set": bson.M{accountRuleField: target, "updated_at": time.Now()}}); err != nil {
+			return usage, err
+		}
+		if err := moveReferences(ctx, db, accountSettingRefs, id, target); err != nil {
+			return usage, err
+		}
+
+	case DeleteCascade:
+		if usage.Transactions > 0 {
+			deleted, err := DeleteTransactions(ctx, db, accountTransactionsFilter(id))
+			if err != nil {
+				return usage, err
+			}
+			if err := RecordTransactionDeletes(ctx, db, deleted); err != nil {
+				return usage, err
+			}
+		}
+		if _, err := db.Collection("recurrences").DeleteMany(ctx, accountRecurrencesFilter(id)); err != nil {
+			return usage, err
+		}
+		// The condition stays, so the rules can't match anything else once re-enabled
+		if detached, err = detach(ctx, db, "rules", accountRuleField, detachDisable,
+			bson.M{accountRuleField: id, "disabled": bson.M{"$ne": true}},
+			bson.M{"$set": bson.M{"disabled": true, "updated_at": time.Now()}},
+		); err != nil {
+			return usage, err
+		}
+		settings, err := detachReferences(ctx, db, accountSettingRefs, id)
+		if err != nil {
+			return usage, err
+		}
+		detached = append(detached, settings...)
+
+	default:
+		return usage, errors.New("invalid delete mode")
+	}
+
+	if err := moveToTrash(ctx, accountsCol, id, detached); err != nil {
+		return usage, err
+	}
+	return usage, RecordAudit(ctx, db, AuditAccount, AuditDelete, id, account, nil)
+}
+
+func currencyOf(account models.Account, baseCurrency string) string {
+	if account.Currency == "" {
+		return baseCurrency
+	}
+	return account.Currency
+}
+
+// reassignAccountTransactions moves the transactions of an account to target one by one through the ledger,
+// so the balances and credit card statement periods follow them
+func reassignAccountTransactions(ctx context.Context, db *mongo.Database, from, to primitive.ObjectID) error {
+	cursor, err := db.Collection("transactions").Find(ctx, utils.NotDeleted(accountTransactionsFilter(from)))
+	if err != nil {
+		return err
+	}
+	var transactions []models.Transaction
+	if err := cursor.All(ctx, &transactions); err != nil {
+		return err
+	}
+
+	for _, transaction := range transactions {
+		if transaction.Account == from {
+			transaction.Account = to
+		}
+		if transaction.DestinationAccount == from {
+			transaction.DestinationAccount = to
+		}
+		if transaction.Type == "transfer" && transaction.Account == transaction.DestinationAccount {
+			return ErrReassignSelfTransfer
+		}
+		if err := AssignStatementPeriod(ctx, db, &transaction); err != nil {
+			return err
+		}
+
+		set := bson.M{"updated_at": time.Now()}
+		if !transaction.Account.IsZero() {
+			set["account_id"] = transaction.Account
+		}
+		if !transaction.DestinationAccount.IsZero() {
+			set["destination_account_id"] = transaction.DestinationAccount
+		}
+		update := bson.M{"$set": set}
+		if transaction.StatementPeriod == "" {
+			update["$unset"] = bson.M{"statement_period": ""}
+		} else {
+			set["statement_period"] = transaction.StatementPeriod
+		}
+		before, after, err := UpdateTransaction(ctx, db, transaction.ID, update)
+		if err != nil {
+			return err
+		}
+		if err := RecordAudit(ctx, db, AuditTransaction, AuditUpdate, transaction.ID, before, after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteCategory moves a category to the trash with everything that uses it handled by mode: refused while in use,
+// moved to target (same type) or deleted along with it. Split transactions go whole when one of their
+// splits is cascaded. Subcategories move up to the deleted category's parent.
+// Every change is written to the audit log. It returns the usage found and must run inside utils.RunInTransaction
+func DeleteCategory(ctx context.Context, db *mongo.Database, id primitive.ObjectID, mode string, target primitive.ObjectID) (ReferenceUsage, error) {
+	categoriesCol := db.Collection("categories")
+
+	var category models.Category
+	if err := categoriesCol.FindOne(ctx, utils.NotDeleted(bson.M{"_id": id})).Decode(&category); err != nil {
+		return ReferenceUsage{}, err
+	}
+	usage, err := CategoryUsage(ctx, db, id)
+	if err != nil {
+		return usage, err
+	}
+
+	var detached []detachedReference
+	switch mode {
+	case DeleteRefuse:
+		if usage.InUse() {
+			return usage, ErrReferenceInUse
+		}
+		if detached, err = removeCategoryFromSettings(ctx, db, id); err != nil {
+			return usage, err
+		}
+
+	case DeleteReassign:
+		var replacement models.Category
+		if err := categoriesCol.FindOne(ctx, utils.NotDeleted(bson.M{"_id": target})).Decode(&replacement); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return usage, ErrReassignTargetMissing
+			}
+			return usage, err
+		}
+		if target == id || replacement.Type != category.Type {
+			return usage, ErrReassignTargetInvalid
+		}
+		if err := reassignCategoryTransactions(ctx, db, id, target); err != nil {
+			return usage, err
+		}
+		if _, err := db.Collection("recurrences").UpdateMany(ctx, bson.M{"template.category_id": id}, bson.M{"$set": bson.M{"template.category_id": target}}); err != nil {
+			return usage, err
+		}
+		if err := moveReferences(ctx, db, categorySettingRefs, id, target); err != nil {
+			return usage, err
+		}
+		_, err := db.Collection("budgets").UpdateMany(ctx,
+			bson.M{"category_ids": id},
+			mongo.Pipeline{{{Key: "$set", Value: bson.M{"category_ids": bson.M{"$setUnion": bson.A{
+				bson.M{"$filter": bson.M{"input": "$category_ids", "cond": bson.M{"$ne": bson.A{"$$this", id}}}},
+				bson.A{target},
+			}}}}}},
+		)
+		if err != nil {
+			return usage, err
+		}
+
+	case DeleteCascade:
+		if usage.Transactions > 0 {
+			deleted, err := DeleteTransactions(ctx, db, categoryTransactionsFilter(id))
+			if err != nil {
+				return usage, err
+			}
+			if err := RecordTransactionDeletes(ctx, db, deleted); err != nil {
+				return usage, err
+			}
+		}
+		if _, err := db.Collection("recurrences").DeleteMany(ctx, bson.M{"template.category_id": id}); err != nil {
+			return usage, err
+		}
+		if detached, err = removeCategoryFromSettings(ctx, db, id); err != nil {
+			return usage, err
+		}
+
+	default:
+		return usage, errors.New("invalid delete mode")
+	}
+
+	if usage.Subcategories > 0 {
+		lifted, err := liftSubcategories(ctx, db, category)
+		if err != nil {
+			return usage, err
+		}
+		detached = append(detached, lifted...)
+	}
+
+	if err := moveToTrash(ctx, categoriesCol, id, detached); err != nil {
+		return usage, err
+	}
+	return usage, RecordAudit(ctx, db, AuditCategory, AuditDelete, id, category, nil)
+}
+
+// liftSubcategories moves the subcategories of a category up to its parent
+func liftSubcategories(ctx context.Context, db *mongo.Database, category models.Category) ([]detachedReference, error) {
+	col := db.Collection("categories")
+
+	cursor, err := col.Find(ctx, utils.NotDeleted(bson.M{"parent_id": category.ID}))
+	if err != nil {
+		return nil, err
+	}
+	var children []models.Category
+	if err := cursor.All(ctx, &children); err != nil {
+		return nil, err
+	}
+
+	update := bson.M{"$unset": bson.M{"parent_id": ""}, "$set": bson.M{"updated_at": time.Now()}}
+	if !category.ParentID.IsZero() {
+		update = bson.M{"$set": bson.M{"parent_id": category.ParentID, "updated_at": time.Now()}}
+	}
+	detached := make([]detachedReference, 0, len(children))
+	for _, child := range children {
+		var after models.Category
+		err := col.FindOneAndUpdate(ctx, bson.M{"_id": child.ID}, utils.BumpVersion(update), options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&after)
+		if err != nil {
+			return nil, err
+		}
+		if err := RecordAudit(ctx, db, AuditCategory, AuditUpdate, child.ID, child, after); err != nil {
+			return nil, err
+		}
+		detached = append(detached, detachedReference{Collection: "categories", ID: child.ID, Field: "parent_id", Action: detachLift, Replaced: category.ParentID})
+	}
+	return detached, nil
+}
+
+// reassignCategoryTransactions moves the transactions and splits of a category to target. Categories don't
+// move balances, so plain updates do, with the documents read around them for the audit log
+func reassignCategoryTransactions(ctx context.Context, db *mongo.Database, from, to primitive.ObjectID) error {
+	col := db.Collection("transactions")
+
+	cursor, err := col.Find(ctx, categoryTransactionsFilter(from))
+	if err != nil {
+		return err
+	}
+	var before []models.Transaction
+	if err := cursor.All(ctx, &before); err != nil {
+		return err
+	}
+	if len(before) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	if _, err := col.UpdateMany(ctx, bson.M{"category_id": from}, utils.BumpVersion(bson.M{"$set": bson.M{"category_id": to, "updated_at": now}})); err != nil {
+		return err
+	}
+	_, err = col.UpdateMany(ctx,
+		bson.M{"splits.category_id": from},
+		utils.BumpVersion(bson.M{"$set": bson.M{"splits.$[split].category_id": to, "updated_at": now}}),
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"split.category_id": from}}}),
+	)
+	if err != nil {
+		return err
+	}
+
+	changes := make([]TransactionChange, 0, len(before))
+	for _, transaction := range before {
+		var after models.Transaction
+		if err := col.FindOne(ctx, bson.M{"_id": transaction.ID}).Decode(&after); err != nil {
+			return err
+		}
+		changes = append(changes, TransactionChange{Before: transaction, After: after})
+	}
+	return RecordTransactionUpdates(ctx, db, changes)
+}
+
+// removeCategoryFromSettings drops a category from the rules, import profiles and budgets
+func removeCategoryFromSettings(ctx context.Context, db *mongo.Database, id primitive.ObjectID) ([]detachedReference, error) {
+	detached, err := detachReferences(ctx, db, categorySettingRefs, id)
+	if err != nil {
+		return nil, err
+	}
+	budgets, err := detach(ctx, db, "budgets", "category_ids", detachPull, bson.M{"category_ids": id}, bson.M{"$pull": bson.M{"category_ids": id}})
+	if err != nil {
+		return nil, err
+	}
+	return append(detached, budgets...), nil
+}
+
+// IsReferenceError reports whether err is a client error of a delete with references
+func IsReferenceError(err error) bool {
+	return errors.Is(err, ErrReassignTargetMissing) || errors.Is(err, ErrReassignTargetInvalid) || errors.Is(err, ErrReassignSelfTransfer)
+}
+
+// ParseDeleteMode reads the delete mode from the reassign_to and cascade query parameters
+func ParseDeleteMode(reassignTo, cascade string) (string, primitive.ObjectID, error) {
+	if reassignTo != "" && cascade == "true" {
+		return "", primitive.NilObjectID, errors.New("reassign_to and cascade can't be combined")
+	}
+	if reassignTo != "" {
+		target, err := primitive.ObjectIDFromHex(reassignTo)
+		if err != nil {
+			return "", primitive.NilObjectID, errors.New("invalid reassign_to")
+		}
+		return DeleteReassign, target, nil
+	}
+	if cascade == "true" {
+		return DeleteCascade, primitive.NilObjectID, nil
+	}
+	return DeleteRefuse, primitive.NilObjectID, nil
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ruleMatcher is a rule with its description regex compiled
+type ruleMatcher struct {
+	rule  models.Rule
+	regex *regexp.Regexp
+}
+
+// RuleSet holds the enabled rules in the order they run
+type RuleSet []ruleMatcher
+
+// RuleFields are the transaction fields rules can change
+type RuleFields struct {
+	CategoryID  primitive.ObjectID `json:"category_id"`
+	Tags        []string           `json:"tags"`
+	Description string             `json:"description"`
+}
+
+// RuleChange is the diff rules make on an existing transaction
+type RuleChange struct {
+	TransactionID primitive.ObjectID   `json:"transaction_id"`
+	RuleIDs       []primitive.ObjectID `json:"rule_ids"`
+	Before        RuleFields           `json:"before"`
+	After         RuleFields           `json:"after"`
+}
+
+func compileRule(rule models.Rule) (ruleMatcher, error) {
+	matcher := ruleMatcher{rule: rule}
+	if rule.Conditions.DescriptionRegex != "" {
+		regex, err := regexp.Compile("(?i)" + rule.Conditions.DescriptionRegex)
+		if err != nil {
+			return matcher, fmt.Errorf("invalid description_regex: %w", err)
+		}
+		matcher.regex = regex
+	}
+	return matcher, nil
+}
+
+// ValidateRule checks that a rule has at least one condition and one action, and that its regex compiles
+func ValidateRule(rule models.Rule) error {
+	conditions := rule.Conditions
+	if conditions.DescriptionContains == "" && conditions.DescriptionRegex == "" && conditions.MinAmount == nil &&
+		conditions.MaxAmount == nil && conditions.AccountID.IsZero() && conditions.Type == "" {
+		return errors.New("a rule needs at least one condition")
+	}
+	if conditions.MinAmount != nil && conditions.MaxAmount != nil && *conditions.MinAmount > *conditions.MaxAmount {
+		return errors.New("min_amount can't be greater than max_amount")
+	}
+	actions := rule.Actions
+	if actions.CategoryID.IsZero() && len(actions.Tags) == 0 && actions.Description == "" {
+		return errors.New("a rule needs at least one action")
+	}
+	_, err := compileRule(rule)
+	return err
+}
+
+// LoadRuleSet loads the enabled rules by ascending priority
+func LoadRuleSet(ctx context.Context, db *mongo.Database) (RuleSet, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "priority", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := db.Collection("rules").Find(ctx, bson.M{"disabled": bson.M{"$ne": true}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var rules []models.Rule
+	if err := cursor.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+
+	set := make(RuleSet, 0, len(rules))
+	for _, rule := range rules {
+		matcher, err := compileRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule.ID.Hex(), err)
+		}
+		set = append(set, matcher)
+	}
+	return set, nil
+}
+
+func (m ruleMatcher) matches(transaction models.Transaction) bool {
+	conditions := m.rule.Conditions
+	if conditions.DescriptionContains != "" &&
+		!strings.Contains(strings.ToLower(transaction.Description), strings.ToLower(conditions.DescriptionContains)) {
+		return false
+	}
+	if m.regex != nil && !m.regex.MatchString(transaction.Description) {
+		return false
+	}
+	if conditions.MinAmount != nil && transaction.Amount < *conditions.MinAmount {
+		return false
+	}
+	if conditions.MaxAmount != nil && transaction.Amount > *conditions.MaxAmount {
+		return false
+	}
+	if !conditions.AccountID.IsZero() && transaction.Account != conditions.AccountID {
+		return false
+	}
+	if conditions.Type != "" && transaction.Type != conditions.Type {
+		return false
+	}
+	return true
+}
+
+// addTags appends the tags the transaction doesn't have yet
+func addTags(tags []string, extra []string) []string {
+	return utils.NormalizeTags(append(append([]string{}, tags...), extra...))
+}
+
+// Apply runs the rules over a transaction and returns the ids of the ones that matched.
+// All rules match against the original description. The first matching rule that sets a category or a
+// description wins; tags add up. Without overwrite a category the transaction already has is kept.
+// Transfers take no category, like with the learned suggestions, so category actions skip them
+func (rs RuleSet) Apply(transaction *models.Transaction, overwrite bool) []primitive.ObjectID {
+	original := *transaction
+	matched := []primitive.ObjectID{}
+	categorySet, descriptionSet := false, false
+
+	for _, matcher := range rs {
+		if !matcher.matches(original) {
+			continue
+		}
+		matched = append(matched, matcher.rule.ID)
+
+		actions := matcher.rule.Actions
+		if !actions.CategoryID.IsZero() && !categorySet && original.Type != "transfer" {
+			if overwrite || original.CategoryID.IsZero() {
+				transaction.CategoryID = actions.CategoryID
+			}
+			categorySet = true
+		}
+		if actions.Description != "" && !descriptionSet {
+			transaction.Description = actions.Description
+			descriptionSet = true
+		}
+		transaction.Tags = addTags(transaction.Tags, actions.Tags)
+
+		if matcher.rule.Stop {
+			break
+		}
+	}
+	return matched
+}
+
+// ApplyRules loads the rules and applies them to a new transaction, keeping the category it was given
+func ApplyRules(ctx context.Context, db *mongo.Database, transaction *models.Transaction) error {
+	rules, err := LoadRuleSet(ctx, db)
+	if err != nil {
+		return err
+	}
+	rules.Apply(transaction, false)
+	return nil
+}
+
+func ruleFields(transaction models.Transaction) RuleFields {
+	return RuleFields{CategoryID: transaction.CategoryID, Tags: transaction.Tags, Description: transaction.Description}
+}
+
+func sameTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// RunRules runs the rules over the existing transactions matching filter and returns what they change.
+// Nothing is written unless commit is set. Category, tags and description don't move balances,
+// so the changes are plain updates, each audited
+func RunRules(ctx context.Context, db *mongo.Database, filter bson.M, overwrite bool, commit bool) ([]RuleChange, error) {
+	rules, err := LoadRuleSet(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	col := db.Collection("transactions")
+	cursor, err := col.Find(ctx, utils.NotDeleted(filter), options.Find().SetSort(bson.D{{Key: "date", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	changes := []RuleChange{}
+	for cursor.Next(ctx) {
+		var transaction models.Transaction
+		if err := cursor.Decode(&transaction); err != nil {
+			return changes, err
+		}
+		original := transaction
+		before := ruleFields(transaction)
+		matched := rules.Apply(&transaction, overwrite)
+		after := ruleFields(transaction)
+		if before.CategoryID == after.CategoryID && before.Description == after.Description && sameTags(before.Tags, after.Tags) {
+			continue
+		}
+
+		if commit {
+			set := bson.M{"tags": after.Tags, "description": after.Description, "updated_at": time.Now()}
+			if !after.CategoryID.IsZero() {
+				set["category_id"] = after.CategoryID
+			}
+			// Each change commits with its audit entry
+			err := utils.RunInTransaction(ctx, db, func(sessCtx mongo.SessionContext) error {
+				if _, err := col.UpdateOne(sessCtx, bson.M{"_id": transaction.ID}, utils.BumpVersion(bson.M{"$set": set})); err != nil {
+					return err
+				}
+				var updated models.Transaction
+				if err := col.FindOne(sessCtx, bson.M{"_id": transaction.ID}).Decode(&updated); err != nil {
+					return err
+				}
+				return RecordTransactionUpdates(sessCtx, db, []TransactionChange{{Before: original, After: updated}})
+			})
+			if err != nil {
+				return changes, err
+			}
+		}
+		changes = append(changes, RuleChange{TransactionID: transaction.ID, RuleIDs: matched, Before: before, After: after})
+	}
+	return changes, cursor.Err()
+}
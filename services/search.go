@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// SearchSort is the only order of search results, most relevant first
+const SearchSort = "-relevance"
+
+// SearchSorts maps SearchSort to the relevance score field, for utils.ParsePage
+var SearchSorts = map[string]string{"relevance": "score"}
+
+// SearchResult is a transaction matching a search, with its relevance score
+type SearchResult struct {
+	models.Transaction `bson:",inline"`
+	Score              float64 `json:"score" bson:"score"`
+}
+
+// SearchTransactions runs a full-text search over the description and tags of the transactions outside
+// the trash, using the text index. The index is in Portuguese and, like every text index, ignores case and
+// diacritics, so "padaria" matches "Padária". Returns up to page.Limit+1 results, for the cursor of the next page
+func SearchTransactions(ctx context.Context, db *mongo.Database, query string, page utils.Page) ([]SearchResult, error) {
+	pipeline := mongo.Pipeline{
+		// $text must be in the first stage
+		{{Key: "$match", Value: utils.NotDeleted(bson.M{"$text": bson.M{"$search": query}})}},
+		{{Key: "$set", Value: bson.M{"score": bson.M{"$meta": "textScore"}}}},
+		{{Key: "$match", Value: page.Filter(bson.M{})}},
+		{{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}, {Key: "_id", Value: -1}}}},
+		{{Key: "$limit", Value: page.Limit + 1}},
+	}
+	cursor, err := db.Collection("transactions").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	results := []SearchResult{}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
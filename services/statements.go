@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const StatementPeriodLayout = "2006-01"
+
+var ErrNotCreditCard = errors.New("account is not a credit card")
+
+// clampDay returns the given day of month, capped to the last day of that month
+func clampDay(year int, month time.Month, day int) time.Time {
+	lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// closingDate returns the closing date of the statement of the given month.
+// Transactions dated on or after the closing date belong to the next statement
+func closingDate(account models.Account, year int, month time.Month) time.Time {
+	return clampDay(year, month, account.ClosureDay)
+}
+
+// StatementPeriodFor returns the statement period (YYYY-MM) a purchase made on date falls into
+func StatementPeriodFor(account models.Account, date time.Time) string {
+	date = date.UTC()
+	closing := closingDate(account, date.Year(), date.Month())
+	if !date.Before(closing) {
+		closing = closingDate(account, date.Year(), date.Month()+1)
+	}
+	return closing.Format(StatementPeriodLayout)
+}
+
+// PaymentPeriodFor returns the statement a payment made on date pays by default:
+// the last statement that closed before the payment
+func PaymentPeriodFor(account models.Account, date time.Time) string {
+	period, _ := time.Parse(StatementPeriodLayout, StatementPeriodFor(account, date))
+	return period.AddDate(0, -1, 0).Format(StatementPeriodLayout)
+}
+
+// StatementDates returns the opening (inclusive), closing (exclusive) and due dates of a period
+func StatementDates(account models.Account, period string) (time.Time, time.Time, time.Time, error) {
+	p, err := time.Parse(StatementPeriodLayout, period)
+	if err != nil {
+		return time.Time{}, time.Time{}, time.Time{}, fmt.Errorf("invalid statement period '%s' (expected YYYY-MM)", period)
+	}
+	closing := closingDate(account, p.Year(), p.Month())
+	opening := closingDate(account, p.Year(), p.Month()-1)
+
+	// Bills are due in the closing month when the pay day comes after the closure day, otherwise in the next one
+	due := clampDay(p.Year(), p.Month(), account.PayDay)
+	if account.PayDay <= account.ClosureDay {
+		due = clampDay(p.Year(), p.Month()+1, account.PayDay)
+	}
+	return opening, closing, due, nil
+}
+
+// AssignStatementPeriod fills StatementPeriod for transactions touching a credit card account.
+// Purchases always follow their date, payments keep the period sent by the client if any
+func AssignStatementPeriod(ctx context.Context, db *mongo.Database, transaction *models.Transaction) error {
+	accountsCol := db.Collection("accounts")
+
+	if transaction.Type == "transfer" && !transaction.DestinationAccount.IsZero() {
+		var destination models.Account
+		err := accountsCol.FindOne(ctx, utils.NotDeleted(bson.M{"_id": transaction.DestinationAccount})).Decode(&destination)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+		if destination.Type == "credit_card" {
+			if transaction.StatementPeriod == "" {
+				transaction.StatementPeriod = PaymentPeriodFor(destination, transaction.Date)
+			} else if _, err := time.Parse(StatementPeriodLayout, transaction.StatementPeriod); err != nil {
+				return fmt.Errorf("invalid statement_period '%s' (expected YYYY-MM)", transaction.StatementPeriod)
+			}
+			return nil
+		}
+	}
+
+	transaction.StatementPeriod = ""
+	if transaction.Account.IsZero() {
+		return nil
+	}
+	var account models.Account
+	err := accountsCol.FindOne(ctx, utils.NotDeleted(bson.M{"_id": transaction.Account})).Decode(&account)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+	if account.Type == "credit_card" {
+		transaction.StatementPeriod = StatementPeriodFor(account, transaction.Date)
+	}
+	return nil
+}
+
+// BuildStatements sorts every transaction of a credit card account into its billing cycle.
+// The current open statement is always included, even without transactions
+func BuildStatements(ctx context.Context, db *mongo.Database, accountID primitive.ObjectID, now time.Time) ([]models.Statement, error) {
+	return buildStatements(ctx, db, accountID, now, "")
+}
+
+// BuildStatement returns a single billing cycle with its transactions, empty when nothing was charged in it
+func BuildStatement(ctx context.Context, db *mongo.Database, accountID primitive.ObjectID, period string, now time.Time) (models.Statement, error) {
+	statements, err := buildStatements(ctx, db, accountID, now, period)
+	if err != nil {
+		return models.Statement{}, err
+	}
+	for _, st := range statements {
+		if st.Period == period {
+			return st, nil
+		}
+	}
+	return models.Statement{}, fmt.Errorf("statement %s not found", period)
+}
+
+func buildStatements(ctx context.Context, db *mongo.Database, accountID primitive.ObjectID, now time.Time, period string) ([]models.Statement, error) {
+	var account models.Account
+	if err := db.Collection("accounts").FindOne(ctx, utils.NotDeleted(bson.M{"_id": accountID})).Decode(&account); err != nil {
+		return nil, err
+	}
+	if account.Type != "credit_card" || account.ClosureDay == 0 {
+		return nil, ErrNotCreditCard
+	}
+
+	filter := utils.NotDeleted(bson.M{"$or": bson.A{
+		bson.M{"account_id": accountID},
+		bson.M{"destination_account_id": accountID, "type": "transfer"},
+	}})
+	cursor, err := db.Collection("transactions").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var transactions []models.Transaction
+	if err := cursor.All(ctx, &transactions); err != nil {
+		return nil, err
+	}
+
+	byPeriod := map[string]*models.Statement{}
+	statementFor := func(period string) (*models.Statement, error) {
+		if st, ok := byPeriod[period]; ok {
+			return st, nil
+		}
+		opening, closing, due, err := StatementDates(account, period)
+		if err != nil {
+			return nil, err
+		}
+		st := &models.Statement{
+			AccountID:    accountID,
+			Period:       period,
+			OpeningDate:  opening,
+			ClosingDate:  closing,
+			DueDate:      due,
+			Transactions: []models.Transaction{},
+		}
+		byPeriod[period] = st
+		return st, nil
+	}
+
+	if _, err := statementFor(StatementPeriodFor(account, now)); err != nil {
+		return nil, err
+	}
+	if period != "" {
+		if _, err := statementFor(period); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, t := range transactions {
+		isPayment := t.Type == "transfer" && t.DestinationAccount == accountID
+		period := t.StatementPeriod
+		if period == "" {
+			if isPayment {
+				period = PaymentPeriodFor(account, t.Date)
+			} else {
+				period = StatementPeriodFor(account, t.Date)
+			}
+		}
+		st, err := statementFor(period)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case isPayment:
+			if t.DestinationAmount != 0 {
+				st.Paid += t.DestinationAmount
+			} else {
+				st.Paid += t.Amount
+			}
+			if st.PaidAt == nil || t.Date.After(*st.PaidAt) {
+				date := t.Date
+				st.PaidAt = &date
+			}
+		case t.Type == "income":
+			st.Credits += t.Amount
+		default: // expenses and transfers out of the card
+			st.Charges += t.Amount
+		}
+		st.Transactions = append(st.Transactions, t)
+	}
+
+	statements := make([]models.Statement, 0, len(byPeriod))
+	for _, st := range byPeriod {
+		st.Total = st.Charges - st.Credits
+		st.Outstanding = st.Total - st.Paid
+		switch {
+		case now.Before(st.ClosingDate):
+			st.Status = "open"
+		case st.Outstanding <= 0:
+			st.Status = "paid"
+		default:
+			st.Status = "closed"
+		}
+		if st.Status != "paid" {
+			st.PaidAt = nil
+		}
+		sort.Slice(st.Transactions, func(i, j int) bool {
+			return st.Transactions[i].Date.Before(st.Transactions[j].Date)
+		})
+		statements = append(statements, *st)
+	}
+	sort.Slice(statements, func(i, j int) bool { return statements[i].Period > statements[j].Period })
+
+	return statements, nil
+}
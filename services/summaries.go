@@ -2,6 +2,7 @@ package services
 
 import (
 	"fmt"
+	"math"
 	"strings"
 
 	"github.com/1v4n-ML/finance-tracker-api/models"
@@ -10,30 +11,80 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// moneyFields are stored in cents, so metrics over them are turned back into models.Money for the response
+var moneyFields = map[string]bool{"amount": true}
+
 func BuildAggregationPipeline(req models.AggregationRequest) (mongo.Pipeline, error) {
-	pipeline := mongo.Pipeline{}
-
-	// 1. $match stage (Filters)
-	matchStage := bson.D{}
-	if len(req.Filters) > 0 {
-		filters := bson.D{}
-		for _, f := range req.Filters {
-			mongoOp, err := utils.MapOperator(f.Operator)
-			if err != nil {
-				return nil, fmt.Errorf("filter error: %w", err)
-			}
+	// Transactions in the trash never count
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: utils.NotDeleted(bson.M{})}}}
 
-			parsedValue, err := utils.ParseFilterValue(f.Field, f.Value, f.Operator)
-			if err != nil {
-				return nil, fmt.Errorf("filter value error: %w", err)
-			}
+	// 0. Transfers only move money between accounts, so they stay out of income/expense totals by default
+	if !req.IncludeTransfers {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.D{{Key: "type", Value: bson.D{{Key: "$ne", Value: "transfer"}}}}}})
+	}
+
+	// 1. $match stage (Filters). Split expansion and the category roll-up rewrite category_id and amount,
+	// so filters on those run after them; the others (date, type, account...) run first and can use the indexes
+	expanded := usesCategory(req)
+	filters, rewrittenFilters := bson.D{}, bson.D{}
+	for _, f := range req.Filters {
+		mongoOp, err := utils.MapOperator(f.Operator)
+		if err != nil {
+			return nil, fmt.Errorf("filter error: %w", err)
+		}
+
+		parsedValue, err := utils.ParseFilterValue(f.Field, f.Value, f.Operator)
+		if err != nil {
+			return nil, fmt.Errorf("filter value error: %w", err)
+		}
+
+		// Append filter condition. If the same field appears multiple times (e.g., date range),
+		// MongoDB implicitly handles it with $and.
+		condition := bson.E{Key: f.Field, Value: bson.D{{Key: mongoOp, Value: parsedValue}}}
+		if f.Field == "category_id" || (expanded && f.Field == "amount") {
+			rewrittenFilters = append(rewrittenFilters, condition)
+		} else {
+			filters = append(filters, condition)
+		}
+	}
+	if len(filters) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: filters}})
+	}
+
+	// 1.1 Split expansion - when categories matter, a split transaction counts once per split,
+	// each under its own category and amount. Count metrics then count splits
+	if expanded {
+		pipeline = append(pipeline, splitExpansionStages()...)
+	}
 
-			// Append filter condition. If the same field appears multiple times (e.g., date range),
-			// MongoDB implicitly handles it with $and.
-			filters = append(filters, bson.E{Key: f.Field, Value: bson.D{{Key: mongoOp, Value: parsedValue}}})
+	// 1.2 Category roll-up - with a category level, every category is replaced by its ancestor at that
+	// level, so filtering or grouping by a parent covers the transactions of all its subcategories
+	if req.CategoryLevel != nil {
+		pipeline = append(pipeline, categoryRollupStages(*req.CategoryLevel)...)
+	}
+	if len(rewrittenFilters) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: rewrittenFilters}})
+	}
+
+	// 1.3 Currency conversion (Optional) - every amount is turned into the requested currency
+	if req.ConvertTo != "" {
+		target := strings.ToUpper(req.ConvertTo)
+		if len(target) != 3 {
+			return nil, fmt.Errorf("invalid convertTo currency '%s'", req.ConvertTo)
+		}
+		pipeline = append(pipeline, currencyConversionStages(target)...)
+	}
+
+	// 1.4 Tag grouping (Optional) - one row per tag, untagged transactions are grouped under null.
+	// A transaction with several tags counts under each of them, so tag totals can add up to more than the whole
+	for _, groupByField := range req.GroupBy {
+		if groupByField == "tags" {
+			pipeline = append(pipeline, bson.D{{Key: "$unwind", Value: bson.D{
+				{Key: "path", Value: "$tags"},
+				{Key: "preserveNullAndEmptyArrays", Value: true},
+			}}})
+			break
 		}
-		matchStage = bson.D{{Key: "$match", Value: filters}}
-		pipeline = append(pipeline, matchStage)
 	}
 
 	// 2. $group stage (Grouping and Metrics)
@@ -137,3 +188,56 @@ func BuildAggregationPipeline(req models.AggregationRequest) (mongo.Pipeline, er
 
 	return pipeline, nil
 }
+
+// usesCategory reports whether a request groups or filters by category
+func usesCategory(req models.AggregationRequest) bool {
+	if req.CategoryLevel != nil {
+		return true
+	}
+	for _, field := range req.GroupBy {
+		if field == "category_id" {
+			return true
+		}
+	}
+	for _, f := range req.Filters {
+		if f.Field == "category_id" {
+			return true
+		}
+	}
+	return false
+}
+
+// splitExpansionStages turns every split into its own document carrying the split's category and amount.
+// Transactions without splits pass through unchanged
+func splitExpansionStages() mongo.Pipeline {
+	parts := bson.M{"$cond": bson.A{
+		bson.M{"$gt": bson.A{bson.M{"$size": bson.M{"$ifNull": bson.A{"$splits", bson.A{}}}}, 0}},
+		"$splits",
+		bson.A{bson.M{"category_id": "$category_id", "amount": "$amount"}},
+	}}
+	return mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"_part": parts}}},
+		{{Key: "$unwind", Value: "$_part"}},
+		{{Key: "$set", Value: bson.M{"category_id": "$_part.category_id", "amount": "$_part.amount"}}},
+		{{Key: "$unset", Value: bson.A{"_part", "splits"}}},
+	}
+}
+
+// FormatMoneyMetrics converts the sum/avg metrics computed over money fields from raw cents into models.Money
+func FormatMoneyMetrics(req models.AggregationRequest, results []bson.M) {
+	for _, m := range req.Metrics {
+		if m.Operation == "count" || !moneyFields[m.Field] {
+			continue
+		}
+		for _, row := range results {
+			switch v := row[m.Name].(type) {
+			case int64:
+				row[m.Name] = models.Money(v)
+			case int32:
+				row[m.Name] = models.Money(v)
+			case float64: // averages
+				row[m.Name] = models.Money(math.Round(v))
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"github.com/1v4n-ML/finance-tracker-api/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrTagsRequired is returned when the source or target tags are missing or blank
+var ErrTagsRequired = errors.New("source and target tags are required")
+
+// TagUsage is a tag with the number of transactions carrying it
+type TagUsage struct {
+	Tag      string    `json:"tag" bson:"_id"`
+	Count    int       `json:"count" bson:"count"`
+	LastUsed time.Time `json:"last_used" bson:"last_used"`
+}
+
+// ListTags returns every tag in use, most used first
+func ListTags(ctx context.Context, db *mongo.Database) ([]TagUsage, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: utils.NotDeleted(bson.M{"tags.0": bson.M{"$exists": true}})}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$group", Value: bson.M{
+			"_id":       "$tags",
+			"count":     bson.M{"$sum": 1},
+			"last_used": bson.M{"$max": "$date"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+	}
+	cursor, err := db.Collection("transactions").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	tags := []TagUsage{}
+	if err := cursor.All(ctx, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// replaceTags builds an update pipeline swapping the sources for target in the array at path,
+// keeping the order of the other tags and never repeating target
+func replaceTags(path string, sources []string, target string) mongo.Pipeline {
+	kept := bson.M{"$filter": bson.M{
+		"input": "$" + path,
+		"cond":  bson.M{"$not": bson.A{bson.M{"$in": bson.A{"$$this", sources}}}},
+	}}
+	return mongo.Pipeline{{{Key: "$set", Value: bson.M{path: bson.M{"$let": bson.M{
+		"vars": bson.M{"kept": kept},
+		"in": bson.M{"$cond": bson.A{
+			bson.M{"$in": bson.A{target, "$$kept"}},
+			"$$kept",
+			bson.M{"$concatArrays": bson.A{"$$kept", bson.A{target}}},
+		}},
+	}}}}}}
+}
+
+// MergeTags replaces the source tags with target on every transaction and rule action.
+// Renaming is merging a single source. It returns how many transactions changed
+func MergeTags(ctx context.Context, db *mongo.Database, sources []string, target string) (int64, error) {
+	sources = utils.NormalizeTags(sources)
+	normalized := utils.NormalizeTags([]string{target})
+	if len(sources) == 0 || len(normalized) == 0 {
+		return 0, ErrTagsRequired
+	}
+	target = normalized[0]
+
+	var modified int64
+	err := utils.RunInTransaction(ctx, db, func(sessCtx mongo.SessionContext) error {
+		// Pipeline updates can't $inc, so the version is bumped by a $set stage
+		bump := bson.D{{Key: "$set", Value: bson.M{"version": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$version", 0}}, 1}}}}}
+		col := db.Collection("transactions")
+		filter := bson.M{"tags": bson.M{"$in": sources}}
+
+		// The documents are read around the update for the audit log
+		cursor, err := col.Find(sessCtx, filter)
+		if err != nil {
+			return err
+		}
+		var before []models.Transaction
+		if err := cursor.All(sessCtx, &before); err != nil {
+			return err
+		}
+		result, err := col.UpdateMany(sessCtx, filter, append(replaceTags("tags", sources, target), bump))
+		if err != nil {
+			return err
+		}
+		modified = result.ModifiedCount
+
+		changes := make([]TransactionChange, 0, len(before))
+		for _, transaction := range before {
+			var after models.Transaction
+			if err := col.FindOne(sessCtx, bson.M{"_id": transaction.ID}).Decode(&after); err != nil {
+				return err
+			}
+			changes = append(changes, TransactionChange{Before: transaction, After: after})
+		}
+		if err := RecordTransactionUpdates(sessCtx, db, changes); err != nil {
+			return err
+		}
+
+		_, err = db.Collection("rules").UpdateMany(sessCtx,
+			bson.M{"actions.tags": bson.M{"$in": sources}},
+			replaceTags("actions.tags", sources, target),
+		)
+		return err
+	})
+	return modified, err
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/1v4n-ML/finance-tracker-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrRestoreDependency is returned when a document can't leave the trash before what it points at
+var ErrRestoreDependency = errors.New("restore blocked")
+
+// IsTrashKind reports whether kind is one of the collections with a trash
+func IsTrashKind(kind string) bool {
+	return kind == "transactions" || kind == "accounts" || kind == "categories"
+}
+
+// Trash holds the deleted documents, most recently deleted first
+type Trash struct {
+	Transactions []models.Transaction `json:"transactions"`
+	Accounts     []models.Account     `json:"accounts"`
+	Categories   []models.Category    `json:"categories"`
+}
+
+func inTrash(filter bson.M) bson.M {
+	filter["deleted_at"] = bson.M{"$exists": true}
+	return filter
+}
+
+func findTrash(ctx context.Context, db *mongo.Database, collection string, results interface{}) error {
+	opts := options.Find().SetSort(bson.D{{Key: "deleted_at", Value: -1}})
+	cursor, err := db.Collection(collection).Find(ctx, inTrash(bson.M{}), opts)
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, results)
+}
+
+// ListTrash returns the deleted transactions, accounts and categories
+func ListTrash(ctx context.Context, db *mongo.Database) (Trash, error) {
+	trash := Trash{
+		Transactions: []models.Transaction{},
+		Accounts:     []models.Account{},
+		Categories:   []models.Category{},
+	}
+	if err := findTrash(ctx, db, "transactions", &trash.Transactions); err != nil {
+		return trash, err
+	}
+	if err := findTrash(ctx, db, "accounts", &trash.Accounts); err != nil {
+		return trash, err
+	}
+	if err := findTrash(ctx, db, "categories", &trash.Categories); err != nil {
+		return trash, err
+	}
+	return trash, nil
+}
+
+// Restore takes a document of the given kind out of the trash and records it in the audit log. Accounts and
+// categories get back the references their delete took from rules, budgets, goals, import profiles and subcategories.
+// It must run inside utils.RunInTransaction
+func Restore(ctx context.Context, db *mongo.Database, kind string, id primitive.ObjectID) error {
+	switch kind {
+	case "transactions":
+		return restoreTransaction(ctx, db, id)
+	case "accounts":
+		var before, after models.Account
+		if err := restoreDocument(ctx, db, "accounts", id, &before, &after); err != nil {
+			return err
+		}
+		if err := RestoreReferences(ctx, db, "accounts", id); err != nil {
+			return err
+		}
+		return RecordAudit(ctx, db, AuditAccount, AuditRestore, id, before, after)
+	case "categories":
+		var before, after models.Category
+		if err := db.Collection("categories").FindOne(ctx, inTrash(bson.M{"_id": id})).Decode(&before); err != nil {
+			return err
+		}
+		if err := requireLive(ctx, db, "categories", before.ParentID, "parent category"); err != nil {
+			return err
+		}
+		if err := restoreDocument(ctx, db, "categories", id, &before, &after); err != nil {
+			return err
+		}
+		if err := RestoreReferences(ctx, db, "categories", id); err != nil {
+			return err
+		}
+		return RecordAudit(ctx, db, AuditCategory, AuditRestore, id, before, after)
+	}
+	return fmt.Errorf("unknown trash kind %s", kind)
+}
+
+// restoreTransaction puts a transaction back on the balances. Its accounts and categories, the ones
+// of its splits included, must be live
+func restoreTransaction(ctx context.Context, db *mongo.Database, id primitive.ObjectID) error {
+	var before, after models.Transaction
+	if err := db.Collection("transactions").FindOne(ctx, inTrash(bson.M{"_id": id})).Decode(&before); err != nil {
+		return err
+	}
+	if err := requireLive(ctx, db, "accounts", before.Account, "account"); err != nil {
+		return err
+	}
+	if err := requireLive(ctx, db, "accounts", before.DestinationAccount, "destination account"); err != nil {
+		return err
+	}
+	if err := requireLive(ctx, db, "categories", before.CategoryID, "category"); err != nil {
+		return err
+	}
+	for _, split := range before.Splits {
+		if err := requireLive(ctx, db, "categories", split.CategoryID, "split category"); err != nil {
+			return err
+		}
+	}
+
+	if err := restoreDocument(ctx, db, "transactions", id, &before, &after); err != nil {
+		return err
+	}
+	if err := ApplyToBalances(ctx, db, after, 1); err != nil {
+		return err
+	}
+	return RecordAudit(ctx, db, AuditTransaction, AuditRestore, id, before, after)
+}
+
+// requireLive fails when the referenced document is in the trash. A document already purged doesn't
+// block, the reference is just left dangling as before the trash existed
+func requireLive(ctx context.Context, db *mongo.Database, collection string, id primitive.ObjectID, name string) error {
+	if id.IsZero() {
+		return nil
+	}
+	trashed, err := db.Collection(collection).CountDocuments(ctx, inTrash(bson.M{"_id": id}))
+	if err != nil {
+		return err
+	}
+	if trashed > 0 {
+		return fmt.Errorf("%w: its %s %s is in the trash, restore it first", ErrRestoreDependency, name, id.Hex())
+	}
+	return nil
+}
+
+// restoreDocument clears the deleted_at marker, decoding the document before and after into the given models.
+// The references the delete detached are still listed on the document, for RestoreReferences
+func restoreDocument(ctx context.Context, db *mongo.Database, collection string, id primitive.ObjectID, before, after interface{}) error {
+	col := db.Collection(collection)
+	err := col.FindOneAndUpdate(ctx,
+		inTrash(bson.M{"_id": id}),
+		bson.M{"$unset": bson.M{"deleted_at": ""}, "$set": bson.M{"updated_at": time.Now()}, "$inc": bson.M{"version": 1}},
+	).Decode(before)
+	if err != nil {
+		return err
+	}
+	return col.FindOne(ctx, bson.M{"_id": id}).Decode(after)
+}
+
+// PurgeTrashService permanently deletes the documents that have been in the trash for more than
+// retentionDays. Their balances were already reverted when they were deleted
+func PurgeTrashService(db *mongo.Database, ctx context.Context, retentionDays int) (int64, error) {
+	log.Println("Starting scheduled task: purging the trash...")
+
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	var purged int64
+	for _, collection := range []string{"transactions", "accounts", "categories"} {
+		result, err := db.Collection(collection).DeleteMany(ctx, bson.M{"deleted_at": bson.M{"$lt": cutoff}})
+		if err != nil {
+			log.Printf("ERROR in scheduler: failed to purge %s: %v", collection, err)
+			return purged, err
+		}
+		purged += result.DeletedCount
+	}
+
+	log.Printf("Scheduled task finished: %d documents purged from the trash.", purged)
+	return purged, nil
+}
@@ -1,22 +1,31 @@
 package utils
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/1v4n-ML/finance-tracker-api/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func ParseDateToISO(date string) (time.Time, error) {
-	var layout = os.Getenv("DATE_LAYOUT")
+	return ParseDateWithLayout(date, os.Getenv("DATE_LAYOUT"))
+}
+
+// ParseDateWithLayout parses date like ParseDateToISO, with layout instead of the configured DATE_LAYOUT
+func ParseDateWithLayout(date string, layout string) (time.Time, error) {
 	d, err := time.Parse(layout, date)
 	if err != nil {
 		return time.Time{}, fmt.Errorf("failed parsing date: %v", err)
@@ -31,6 +40,34 @@ func NewContextWithTimeout(baseCtx context.Context, timeout time.Duration) (cont
 	return context.WithTimeout(baseCtx, timeout)
 }
 
+// NotDeleted adds to filter the condition that keeps documents in the trash out, and returns it
+func NotDeleted(filter bson.M) bson.M {
+	filter["deleted_at"] = bson.M{"$exists": false}
+	return filter
+}
+
+// MatchVersion adds to filter the condition that the document is at version, and returns it.
+// Documents written before versioning have no version field and are at version 0
+func MatchVersion(filter bson.M, version int64) bson.M {
+	if version == 0 {
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
+	} else {
+		filter["version"] = version
+	}
+	return filter
+}
+
+// BumpVersion adds the version increment to an update document, and returns it
+func BumpVersion(update bson.M) bson.M {
+	inc, _ := update["$inc"].(bson.M)
+	if inc == nil {
+		inc = bson.M{}
+	}
+	inc["version"] = 1
+	update["$inc"] = inc
+	return update
+}
+
 func MapOperator(op string) (string, error) {
 	switch op {
 	case "eq":
@@ -56,7 +93,7 @@ func MapOperator(op string) (string, error) {
 
 func ParseFilterValue(field string, value interface{}, operator string) (interface{}, error) {
 	// Handle fields expecting ObjectID
-	if field == "category_id" || field == "account_id" || field == "_id" {
+	if field == "category_id" || field == "account_id" || field == "destination_account_id" || field == "goal_id" || field == "_id" {
 		// Handle 'in'/'nin' operators which expect an array
 		if operator == "in" || operator == "nin" {
 			valSlice, ok := value.([]interface{})
@@ -114,28 +151,48 @@ func ParseFilterValue(field string, value interface{}, operator string) (interfa
 		return t, nil
 	}
 
-	// Handle numeric fields (like amount)
+	// Handle money fields (like amount), stored in cents
 	if field == "amount" {
 		switch v := value.(type) {
 		case float64:
-			return v, nil
+			return models.MoneyFromFloat(v), nil
 		case float32:
-			return float64(v), nil
+			return models.MoneyFromFloat(float64(v)), nil
 		case int:
-			return float64(v), nil
+			return models.Money(v) * 100, nil
 		case int64:
-			return float64(v), nil
+			return models.Money(v) * 100, nil
 		case string: // Allow string representation of numbers
-			f, err := strconv.ParseFloat(v, 64)
+			m, err := models.ParseMoney(v)
 			if err != nil {
 				return nil, fmt.Errorf("invalid numeric string '%s' for field '%s': %w", v, field, err)
 			}
-			return f, nil
+			return m, nil
 		default:
 			return nil, fmt.Errorf("unsupported type for numeric field '%s'", field)
 		}
 	}
 
+	// Tags are stored normalized, so the filter values are normalized the same way
+	if field == "tags" {
+		switch v := value.(type) {
+		case string:
+			return strings.ToLower(strings.TrimSpace(v)), nil
+		case []interface{}:
+			tags := make([]string, 0, len(v))
+			for _, element := range v {
+				tag, ok := element.(string)
+				if !ok {
+					return nil, fmt.Errorf("value element for 'tags' must be a string")
+				}
+				tags = append(tags, tag)
+			}
+			return NormalizeTags(tags), nil
+		default:
+			return nil, fmt.Errorf("value for 'tags' must be a string or an array of strings")
+		}
+	}
+
 	// Handle string fields (like type, description)
 	// Allow 'in'/'nin' for string fields too
 	if operator == "in" || operator == "nin" {
@@ -193,11 +250,99 @@ func GetEnvOrDefault(key, defaultValue string) string {
 	return value
 }
 
-func UpdateAccountBalanceOnTransaction(ctx context.Context, db *mongo.Database, transaction models.Transaction, changeFactor float64) error {
+// ValidateTransaction checks the rules that can't be expressed with binding tags
+func ValidateTransaction(transaction models.Transaction) error {
+	if transaction.Type != "transfer" {
+		if !transaction.DestinationAccount.IsZero() {
+			return errors.New("destination_account_id is only allowed for transfers")
+		}
+		return validateSplits(transaction)
+	}
+	if len(transaction.Splits) > 0 {
+		return errors.New("transfers can't be split")
+	}
+	if transaction.Account.IsZero() || transaction.DestinationAccount.IsZero() {
+		return errors.New("transfers require both account_id and destination_account_id")
+	}
+	if transaction.Account == transaction.DestinationAccount {
+		return errors.New("transfer source and destination accounts must be different")
+	}
+	return nil
+}
+
+// validateSplits checks that the splits of a transaction add up to its amount
+func validateSplits(transaction models.Transaction) error {
+	if len(transaction.Splits) == 0 {
+		return nil
+	}
+	if transaction.InstallmentPlan != nil {
+		return errors.New("installment purchases can't be split")
+	}
+	var total models.Money
+	for _, split := range transaction.Splits {
+		total += split.Amount
+	}
+	if total != transaction.Amount {
+		return fmt.Errorf("splits add up to %s but the amount is %s", total, transaction.Amount)
+	}
+	return nil
+}
+
+// NormalizeTags trims and lowercases tags, dropping empty and repeated ones while keeping their order
+func NormalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// RunInTransaction executes fn inside a MongoDB multi-document transaction,
+// committing when fn returns nil and aborting otherwise
+func RunInTransaction(ctx context.Context, db *mongo.Database, fn func(sessCtx mongo.SessionContext) error) error {
+	session, err := db.Client().StartSession()
+	if err != nil {
+		return fmt.Errorf("failed to start session: %w", err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	return err
+}
+
+// UpdateAccountBalanceOnTransaction applies a transaction to the balances it affects.
+// changeFactor is 1 to apply it and -1 to revert it
+func UpdateAccountBalanceOnTransaction(ctx context.Context, db *mongo.Database, transaction models.Transaction, changeFactor models.Money) error {
 	if transaction.Account.IsZero() {
 		return errors.New("transação não possui uma conta associada")
 	}
 
+	accountsCollection := db.Collection("accounts")
+
+	// Transferências movem o valor da conta de origem para a conta de destino
+	if transaction.Type == "transfer" {
+		if transaction.DestinationAccount.IsZero() {
+			return errors.New("transferência não possui uma conta de destino")
+		}
+		if err := incrementBalance(ctx, accountsCollection, transaction.Account, -transaction.Amount*changeFactor); err != nil {
+			return err
+		}
+		// Entre moedas diferentes a conta de destino recebe o valor convertido
+		received := transaction.Amount
+		if transaction.DestinationAmount != 0 {
+			received = transaction.DestinationAmount
+		}
+		return incrementBalance(ctx, accountsCollection, transaction.DestinationAccount, received*changeFactor)
+	}
+
 	// Define o valor da mudança: positivo para income, negativo para expense
 	changeAmount := transaction.Amount
 	if transaction.Type == "expense" {
@@ -207,58 +352,323 @@ func UpdateAccountBalanceOnTransaction(ctx context.Context, db *mongo.Database,
 	// Aplica o fator de mudança (para tratar deleções)
 	finalChange := changeAmount * changeFactor
 
-	accountsCollection := db.Collection("accounts")
+	return incrementBalance(ctx, accountsCollection, transaction.Account, finalChange)
+}
+
+func incrementBalance(ctx context.Context, accountsCollection *mongo.Collection, accountID primitive.ObjectID, change models.Money) error {
 	_, err := accountsCollection.UpdateOne(
 		ctx,
-		bson.M{"_id": transaction.Account},
-		bson.M{"$inc": bson.M{"balance": finalChange}},
+		bson.M{"_id": accountID},
+		bson.M{"$inc": bson.M{"balance": change}},
 	)
 
 	if err != nil {
-		return fmt.Errorf("falha ao atualizar saldo da conta %s: %w", transaction.Account.Hex(), err)
+		return fmt.Errorf("falha ao atualizar saldo da conta %s: %w", accountID.Hex(), err)
 	}
 
 	return nil
 }
 
-func RecalculateAllBalancesService(db *mongo.Database, ctx context.Context) {
-	log.Println("Iniciando tarefa agendada: Recalculando todos os saldos...")
+// RecalculateAllBalancesService é a ferramenta de reparo dos saldos: recalcula o saldo esperado de cada
+// conta a partir das transações e devolve as diferenças encontradas. Com apply, corrige as contas divergentes.
+// A correção só é gravada se o saldo não mudou desde a leitura, para não perder escritas concorrentes
+func RecalculateAllBalancesService(db *mongo.Database, ctx context.Context, apply bool) ([]models.BalanceDifference, error) {
+	log.Println("Iniciando verificação: recalculando todos os saldos...")
 
 	accountsCol := db.Collection("accounts")
 	transactionsCol := db.Collection("transactions")
 
-	// 1. Zera o saldo de todas as contas
-	_, err := accountsCol.UpdateMany(ctx, bson.M{}, bson.M{"$set": bson.M{"balance": 0}})
+	// 1. Soma o efeito das transações na conta de origem: income soma, expense e transfer subtraem.
+	// Transações na lixeira já foram revertidas dos saldos e ficam de fora
+	expected := map[primitive.ObjectID]models.Money{}
+	sourcePipeline := mongo.Pipeline{
+		{{Key: "$match", Value: NotDeleted(bson.M{"account_id": bson.M{"$exists": true}})}},
+		{{Key: "$group", Value: bson.M{
+			"_id": "$account_id",
+			"total": bson.M{"$sum": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$type", "income"}},
+				"$amount",
+				bson.M{"$multiply": bson.A{"$amount", -1}},
+			}}},
+		}}},
+	}
+	if err := sumBalances(ctx, transactionsCol, sourcePipeline, expected); err != nil {
+		return nil, fmt.Errorf("falha ao somar transações: %w", err)
+	}
+
+	// 2. Soma as transferências recebidas, no valor convertido quando houver
+	destinationPipeline := mongo.Pipeline{
+		{{Key: "$match", Value: NotDeleted(bson.M{"type": "transfer", "destination_account_id": bson.M{"$exists": true}})}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$destination_account_id",
+			"total": bson.M{"$sum": bson.M{"$ifNull": bson.A{"$destination_amount", "$amount"}}},
+		}}},
+	}
+	if err := sumBalances(ctx, transactionsCol, destinationPipeline, expected); err != nil {
+		return nil, fmt.Errorf("falha ao somar transferências: %w", err)
+	}
+
+	// 3. Compara com o saldo gravado em cada conta fora da lixeira
+	cursor, err := accountsCol.Find(ctx, NotDeleted(bson.M{}))
 	if err != nil {
-		log.Printf("ERRO no scheduler: Falha ao zerar saldos: %v", err)
-		return
+		return nil, fmt.Errorf("falha ao buscar contas: %w", err)
+	}
+	var accounts []models.Account
+	if err := cursor.All(ctx, &accounts); err != nil {
+		return nil, fmt.Errorf("falha ao decodificar contas: %w", err)
+	}
+
+	differences := []models.BalanceDifference{}
+	for _, account := range accounts {
+		if account.Balance == expected[account.ID] {
+			continue
+		}
+		difference := models.BalanceDifference{
+			AccountID:  account.ID,
+			Name:       account.Name,
+			Stored:     account.Balance,
+			Expected:   expected[account.ID],
+			Difference: expected[account.ID] - account.Balance,
+		}
+		if apply {
+			result, err := accountsCol.UpdateOne(ctx,
+				bson.M{"_id": account.ID, "balance": account.Balance},
+				bson.M{"$set": bson.M{"balance": difference.Expected}},
+			)
+			if err != nil {
+				return differences, fmt.Errorf("falha ao corrigir saldo da conta %s: %w", account.ID.Hex(), err)
+			}
+			difference.Fixed = result.ModifiedCount == 1
+		}
+		differences = append(differences, difference)
 	}
 
-	// 2. Busca todas as transações
-	cursor, err := transactionsCol.Find(ctx, bson.M{})
+	log.Printf("Verificação finalizada: %d contas com saldo divergente.", len(differences))
+	return differences, nil
+}
+
+func sumBalances(ctx context.Context, col *mongo.Collection, pipeline mongo.Pipeline, totals map[primitive.ObjectID]models.Money) error {
+	cursor, err := col.Aggregate(ctx, pipeline)
 	if err != nil {
-		log.Printf("ERRO no scheduler: Falha ao buscar transações: %v", err)
-		return
+		return err
+	}
+	var rows []struct {
+		ID    primitive.ObjectID `bson:"_id"`
+		Total models.Money       `bson:"total"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		totals[row.ID] += row.Total
+	}
+	return nil
+}
+
+type contextKey string
+
+const (
+	actorKey     contextKey = "actor"
+	requestIDKey contextKey = "request_id"
+)
+
+// WithActor returns a context carrying the identity of the API key behind the request
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// ActorFrom returns the identity set by WithActor, or "system" for work not started by a request
+func ActorFrom(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey).(string); ok {
+		return actor
+	}
+	return "system"
+}
+
+// WithRequestID returns a context carrying the id of the request being served
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestIDFrom returns the id set by WithRequestID, empty outside a request
+func RequestIDFrom(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// MergePatch applies an RFC 7396 JSON merge patch to a JSON document: members of the patch replace
+// those of the document, objects are merged recursively and null removes the member
+func MergePatch(document, patch []byte) ([]byte, error) {
+	var target, changes interface{}
+	if err := decodeJSON(document, &target); err != nil {
+		return nil, err
+	}
+	if err := decodeJSON(patch, &changes); err != nil {
+		return nil, err
 	}
-	defer cursor.Close(ctx)
+	return json.Marshal(mergeValue(target, changes))
+}
 
-	// 3. Itera sobre cada transação e atualiza o saldo da conta correspondente
-	for cursor.Next(ctx) {
-		var trans models.Transaction
-		if err := cursor.Decode(&trans); err != nil {
-			log.Printf("ERRO no scheduler: Falha ao decodificar transação durante recalculo: %v", err)
+func mergeValue(target, patch interface{}) interface{} {
+	changes, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	merged, ok := target.(map[string]interface{})
+	if !ok {
+		merged = map[string]interface{}{}
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(merged, key)
 			continue
 		}
+		merged[key] = mergeValue(merged[key], value)
+	}
+	return merged
+}
+
+// decodeJSON keeps numbers as written, so amounts don't go through a float
+func decodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
 
-		// Reutiliza a função que já temos!
-		if err := UpdateAccountBalanceOnTransaction(ctx, db, trans, 1.0); err != nil {
-			log.Printf("ERRO no scheduler: Falha ao atualizar saldo para transação %s: %v", trans.ID.Hex(), err)
+// RemovedFields returns an $unset document for the fields stored for before that after no longer has.
+// _id and version are never removed
+func RemovedFields(before, after interface{}) (bson.M, error) {
+	var old, current bson.M
+	data, err := bson.Marshal(before)
+	if err != nil {
+		return nil, err
+	}
+	if err := bson.Unmarshal(data, &old); err != nil {
+		return nil, err
+	}
+	if data, err = bson.Marshal(after); err != nil {
+		return nil, err
+	}
+	if err := bson.Unmarshal(data, &current); err != nil {
+		return nil, err
+	}
+
+	unset := bson.M{}
+	for key := range old {
+		if _, kept := current[key]; !kept && key != "_id" && key != "version" {
+			unset[key] = ""
 		}
 	}
+	return unset, nil
+}
+
+// Page sizes of the list endpoints
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 500
+)
+
+// ErrInvalidCursor is returned for a cursor that wasn't issued for the requested sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Page is the keyset pagination of a list: documents sorted by Field then _id, starting right after
+// the last document of the previous page. Unlike skip, it costs the same on every page and doesn't
+// shift when documents are added in between
+type Page struct {
+	Sort       string // As requested, e.g. "-date"
+	Field      string
+	Descending bool
+	Limit      int64
+	after      *pageCursor
+}
 
-	if err := cursor.Err(); err != nil {
-		log.Printf("ERRO no scheduler: Erro no cursor de transações: %v", err)
+// pageCursor is the position after which a page starts, encoded in the cursor query parameter
+type pageCursor struct {
+	Sort  string             `bson:"s"`
+	Value bson.RawValue      `bson:"v"`
+	ID    primitive.ObjectID `bson:"id"`
+}
+
+// ParsePage reads the sort, limit and cursor query parameters. sortable maps the sort names an endpoint
+// accepts to their fields; a leading - sorts descending
+func ParsePage(sort, limit, cursor string, sortable map[string]string, defaultSort string) (Page, error) {
+	if sort == "" {
+		sort = defaultSort
+	}
+	page := Page{Sort: sort, Limit: DefaultPageLimit}
+	name := strings.TrimPrefix(sort, "-")
+	page.Descending = name != sort
+	field, ok := sortable[name]
+	if !ok {
+		return page, fmt.Errorf("can't sort by %s", name)
+	}
+	page.Field = field
+
+	if limit != "" {
+		n, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil || n < 1 || n > MaxPageLimit {
+			return page, fmt.Errorf("limit must be between 1 and %d", MaxPageLimit)
+		}
+		page.Limit = n
 	}
 
-	log.Println("Tarefa finalizada: Saldos recalculados com sucesso.")
+	if cursor != "" {
+		data, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return page, ErrInvalidCursor
+		}
+		var after pageCursor
+		if err := bson.Unmarshal(data, &after); err != nil || after.Sort != sort {
+			return page, ErrInvalidCursor
+		}
+		page.after = &after
+	}
+	return page, nil
+}
+
+// Filter adds to filter the condition that skips to the cursor position, and returns it
+func (p Page) Filter(filter bson.M) bson.M {
+	if p.after == nil {
+		return filter
+	}
+	op := "$gt"
+	if p.Descending {
+		op = "$lt"
+	}
+	and, _ := filter["$and"].(bson.A)
+	filter["$and"] = append(and, bson.M{"$or": bson.A{
+		bson.M{p.Field: bson.M{op: p.after.Value}},
+		bson.M{p.Field: p.after.Value, "_id": bson.M{op: p.after.ID}},
+	}})
+	return filter
+}
+
+// FindOptions sorts by the page field then _id and reads one document past the limit,
+// which tells whether there is a next page
+func (p Page) FindOptions() *options.FindOptions {
+	direction := 1
+	if p.Descending {
+		direction = -1
+	}
+	return options.Find().
+		SetSort(bson.D{{Key: p.Field, Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(p.Limit + 1)
+}
+
+// Cursor returns the cursor of the page that starts after last
+func (p Page) Cursor(last interface{}) (string, error) {
+	data, err := bson.Marshal(last)
+	if err != nil {
+		return "", err
+	}
+	document := bson.Raw(data)
+	id, ok := document.Lookup("_id").ObjectIDOK()
+	if !ok {
+		return "", fmt.Errorf("document without an _id")
+	}
+	data, err = bson.Marshal(pageCursor{Sort: p.Sort, Value: document.Lookup(p.Field), ID: id})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
 }